@@ -32,12 +32,11 @@ type Amount int
 func NewAmount(price Price, volume Volume) Amount {
 	return Amount(price * Price(volume))
 }
-func (amt Amount) ToPercent() string {
-	var str, end []byte
-	str = []byte(strconv.Itoa(int(amt)))
-	str, end = str[0:len(str)-2], str[len(str)-2:]
 
-	return toString(str) + "." + string(end) + "%"
+// ToPercent returns a string representation of an amount given in hundredths of a percent.
+func (amt Amount) ToPercent() string {
+	sign, whole, frac := splitDecimal(int(amt))
+	return sign + whole + "." + frac + "%"
 }
 
 // Volume represents a quantity or volume.
@@ -67,11 +66,8 @@ func NewPrice(f float64) Price {
 
 // String returns a string representation of a price value.
 func (p Price) String() string {
-	var amt, end []byte
-	amt = []byte(strconv.Itoa(int(p)))
-	amt, end = amt[0:len(amt)-2], amt[len(amt)-2:]
-
-	return "$" + toString(amt) + "." + string(end)
+	sign, whole, frac := splitDecimal(int(p))
+	return sign + "$" + whole + "." + frac
 }
 
 // ----------------------------------------------------------------------------
@@ -81,6 +77,27 @@ func Divide(top, bottom Price) Amount {
 	return Amount((top*200 + bottom) / (bottom * 2))
 }
 
+// splitDecimal splits an integer with two implied decimal places into its sign,
+// comma-separated whole part, and fractional part.
+func splitDecimal(n int) (sign, whole, frac string) {
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	digits := strconv.Itoa(n)
+	for len(digits) < 3 {
+		digits = "0" + digits
+	}
+	return sign, toString([]byte(digits[:len(digits)-2])), digits[len(digits)-2:]
+}
+
+// roundDiv divides two amounts, rounding half away from zero.
+func roundDiv(n, d Amount) Amount {
+	if (n < 0) != (d < 0) {
+		return (n*2 - d) / (d * 2)
+	}
+	return (n*2 + d) / (d * 2)
+}
+
 // toString takes a byte slice and converts it to a string representation.
 func toString(amt []byte) string {
 	var result []byte
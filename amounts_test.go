@@ -62,6 +62,8 @@ func TestPrice_String(t *testing.T) {
 		{"10k", fields{10000 * 100}, "$10,000.00"},
 		{"100k", fields{100000 * 100}, "$100,000.00"},
 		{"1m", fields{1000000 * 100}, "$1,000,000.00"},
+		{"cents", fields{5}, "$0.05"},
+		{"negative", fields{-100000 * 100}, "-$100,000.00"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -157,6 +159,8 @@ func TestAmount_ToPercent(t *testing.T) {
 		want string
 	}{
 		{"base case", Amount(10 * 100), "10.00%"},
+		{"fraction", Amount(25), "0.25%"},
+		{"negative", Amount(-1250), "-12.50%"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
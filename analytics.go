@@ -0,0 +1,366 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInsufficientHistory is returned when a history spans too little time to be analyzed.
+	ErrInsufficientHistory = errors.New("insufficient history")
+	// ErrNoConvergence is returned when an internal rate of return cannot be solved for.
+	ErrNoConvergence = errors.New("rate of return did not converge")
+)
+
+// Valuation is the value of a portfolio at a point in time. Flow is the external cash
+// deposited into the portfolio at that time, or withdrawn if negative, and is already
+// reflected in Value.
+type Valuation struct {
+	Timestamp time.Time
+	Value     Amount
+	Flow      Amount
+}
+
+// Valuation values a portfolio at a point in time by its NAV.
+// Callers record any external cash flow made at that time in the valuation's Flow.
+func (p *Portfolio) Valuation(t time.Time, marks map[string]Quote) (Valuation, error) {
+	nav, err := p.NAV(marks)
+	if err != nil {
+		return Valuation{}, err
+	}
+	return Valuation{Timestamp: t, Value: nav}, nil
+}
+
+// Returns computes the return of each period between valuations, excluding external cash flows.
+func Returns(history []Valuation) ([]float64, error) {
+	if len(history) < 2 {
+		return nil, ErrInsufficientHistory
+	}
+	returns := make([]float64, 0, len(history)-1)
+	for i := 1; i < len(history); i++ {
+		prev, v := history[i-1], history[i]
+		if v.Timestamp.Before(prev.Timestamp) {
+			return nil, errors.Wrapf(ErrOutOfOrder, "valuation at %s", v.Timestamp)
+		}
+		if prev.Value == 0 {
+			return nil, errors.Wrapf(ErrZeroValue, "valuation at %s", prev.Timestamp)
+		}
+		returns = append(returns, float64(v.Value-v.Flow)/float64(prev.Value)-1)
+	}
+	return returns, nil
+}
+
+// TimeWeightedReturn returns the compounded return of a history, excluding the effect of
+// external cash flows, in hundredths of a percent.
+func TimeWeightedReturn(history []Valuation) (Amount, error) {
+	returns, err := Returns(history)
+	if err != nil {
+		return 0, err
+	}
+	return toBps(compound(returns)), nil
+}
+
+// IRR returns the money-weighted return of a history: the annual rate at which the initial
+// value and external cash flows grow to the final value, in hundredths of a percent.
+func IRR(history []Valuation) (Amount, error) {
+	if len(history) < 2 || !history[len(history)-1].Timestamp.After(history[0].Timestamp) {
+		return 0, ErrInsufficientHistory
+	}
+	start, last := history[0], history[len(history)-1]
+	// Rates are searched by their continuously compounded equivalent, so that rates just
+	// above -100%, such as a large loss annualized over a short span, can be reached.
+	npv := func(growth float64) float64 {
+		discount := func(t time.Time) float64 {
+			return math.Exp(-growth * years(t.Sub(start.Timestamp)))
+		}
+		sum := -float64(start.Value)
+		for _, v := range history[1:] {
+			sum -= float64(v.Flow) * discount(v.Timestamp)
+		}
+		return sum + float64(last.Value)*discount(last.Timestamp)
+	}
+
+	// Bisect for a root, widening the bounds until the net present value changes sign.
+	lo, hi := -1.0, 1.0
+	for npv(lo)*npv(hi) > 0 {
+		if lo, hi = lo*2, hi*2; hi > 64 {
+			return 0, ErrNoConvergence
+		}
+	}
+	if math.IsNaN(npv(lo) * npv(hi)) {
+		return 0, ErrNoConvergence
+	}
+	for i := 0; i < 200 && hi-lo > 1e-10; i++ {
+		mid := (lo + hi) / 2
+		if npv(lo)*npv(mid) <= 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return toBps(math.Expm1((lo + hi) / 2)), nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Drawdown is a decline in value from a peak. Depth is in hundredths of a percent of the peak.
+// Duration runs from the peak until the value recovers to it, or to the end of the history
+// if it never does, in which case Recovered is zero.
+type Drawdown struct {
+	Depth     Amount        `json:"depth"`
+	Peak      time.Time     `json:"peak"`
+	Trough    time.Time     `json:"trough"`
+	Recovered time.Time     `json:"recovered"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// MaxDrawdown returns the largest drawdown of a history.
+// Values are chained by their periodic returns, so external cash flows are not drawdowns.
+func MaxDrawdown(history []Valuation) (Drawdown, error) {
+	returns, err := Returns(history)
+	if err != nil {
+		return Drawdown{}, err
+	}
+	var (
+		max, cur   Drawdown
+		index, top = 1.0, 1.0
+		depth      float64
+	)
+	cur.Peak = history[0].Timestamp
+	for i, r := range returns {
+		t := history[i+1].Timestamp
+		if index *= 1 + r; index >= top {
+			if depth > 0 {
+				cur.Recovered = t
+				cur.Duration = t.Sub(cur.Peak)
+				if cur.Depth > max.Depth {
+					max = cur
+				}
+			}
+			top, depth = index, 0
+			cur = Drawdown{Peak: t}
+			continue
+		}
+		if d := 1 - index/top; d > depth {
+			depth, cur.Depth, cur.Trough = d, toBps(d), t
+		}
+	}
+	if depth > 0 {
+		cur.Duration = history[len(history)-1].Timestamp.Sub(cur.Peak)
+		if cur.Depth > max.Depth {
+			max = cur
+		}
+	}
+	return max, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Ratio is a unitless performance ratio. Ratios that are undefined, such as a profit factor
+// without losing trades, are infinite or NaN and are rendered as "n/a", or null in JSON.
+type Ratio float64
+
+func (r Ratio) String() string {
+	if math.IsInf(float64(r), 0) || math.IsNaN(float64(r)) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f", float64(r))
+}
+
+// MarshalJSON encodes a ratio as a JSON number, or null if it is undefined.
+func (r Ratio) MarshalJSON() ([]byte, error) {
+	if math.IsInf(float64(r), 0) || math.IsNaN(float64(r)) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(math.Round(float64(r)*10000) / 10000)
+}
+
+// Report is a summary of portfolio performance over a history.
+// Returns, volatility and hit rate are in hundredths of a percent; returns are annualized
+// except TotalReturn. IRR is nil if no rate solves the history's cash flows.
+type Report struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Periods      int       `json:"periods"`
+	TotalReturn  Amount    `json:"total_return"`
+	AnnualReturn Amount    `json:"annual_return"`
+	IRR          *Amount   `json:"irr"`
+	Volatility   Amount    `json:"volatility"`
+	Sharpe       Ratio     `json:"sharpe"`
+	Sortino      Ratio     `json:"sortino"`
+	Calmar       Ratio     `json:"calmar"`
+	MaxDrawdown  Drawdown  `json:"max_drawdown"`
+	Trades       int       `json:"trades"`
+	HitRate      Amount    `json:"hit_rate"`
+	GrossProfit  Amount    `json:"gross_profit"`
+	GrossLoss    Amount    `json:"gross_loss"`
+	ProfitFactor Ratio     `json:"profit_factor"`
+}
+
+// Analytics computes performance reports from valuations taken PeriodsPerYear times a year,
+// such as 252 for daily valuations. RiskFree is the annual risk-free rate, such as 0.02,
+// and is the target return for both the Sharpe and Sortino ratios.
+type Analytics struct {
+	PeriodsPerYear float64
+	RiskFree       float64
+}
+
+// NewAnalytics returns a new analytics instance with a risk-free rate of zero.
+func NewAnalytics(periodsPerYear float64) *Analytics {
+	return &Analytics{PeriodsPerYear: periodsPerYear}
+}
+
+// Report analyzes a history of valuations, along with the lot reliefs of its closed trades.
+func (a *Analytics) Report(history []Valuation, trades []LotRelief) (*Report, error) {
+	returns, err := Returns(history)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{Start: history[0].Timestamp, End: history[len(history)-1].Timestamp, Periods: len(returns)}
+	span := years(r.End.Sub(r.Start))
+	if span <= 0 {
+		return nil, ErrInsufficientHistory
+	}
+	if irr, err := IRR(history); err == nil {
+		r.IRR = &irr
+	}
+	if r.MaxDrawdown, err = MaxDrawdown(history); err != nil {
+		return nil, err
+	}
+
+	total := compound(returns)
+	annual := math.Pow(1+total, 1/span) - 1
+	r.TotalReturn, r.AnnualReturn = toBps(total), toBps(annual)
+
+	rf := math.Pow(1+a.RiskFree, 1/a.PeriodsPerYear) - 1
+	var mean, downside float64
+	for _, ret := range returns {
+		mean += ret - rf
+		if ret < rf {
+			downside += (ret - rf) * (ret - rf)
+		}
+	}
+	n := float64(len(returns))
+	mean /= n
+	scale := math.Sqrt(a.PeriodsPerYear)
+	sd := stdDev(returns)
+
+	r.Volatility = toBps(sd * scale)
+	r.Sharpe = Ratio(mean / sd * scale)
+	r.Sortino = Ratio(mean / math.Sqrt(downside/n) * scale)
+	r.Calmar = Ratio(annual / (float64(r.MaxDrawdown.Depth) / 10000))
+
+	var wins int
+	for _, t := range trades {
+		if g := t.Gain(); g > 0 {
+			wins++
+			r.GrossProfit += g
+		} else {
+			r.GrossLoss -= g
+		}
+	}
+	r.Trades = len(trades)
+	if r.Trades != 0 {
+		r.HitRate = roundDiv(Amount(wins*10000), Amount(r.Trades))
+	}
+	r.ProfitFactor = Ratio(float64(r.GrossProfit) / float64(r.GrossLoss))
+	return r, nil
+}
+
+// String renders a report as a table of text.
+func (r *Report) String() string {
+	var (
+		b strings.Builder
+		w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	)
+	recovered := "not recovered"
+	if !r.MaxDrawdown.Recovered.IsZero() {
+		recovered = r.MaxDrawdown.Recovered.Format("2006-01-02")
+	}
+	irr := "n/a"
+	if r.IRR != nil {
+		irr = r.IRR.ToPercent()
+	}
+	fmt.Fprintf(w, "Period\t%s to %s (%d periods)\n", r.Start.Format("2006-01-02"), r.End.Format("2006-01-02"), r.Periods)
+	fmt.Fprintf(w, "Total return\t%s\n", r.TotalReturn.ToPercent())
+	fmt.Fprintf(w, "Annual return\t%s\n", r.AnnualReturn.ToPercent())
+	fmt.Fprintf(w, "IRR\t%s\n", irr)
+	fmt.Fprintf(w, "Volatility\t%s\n", r.Volatility.ToPercent())
+	fmt.Fprintf(w, "Sharpe\t%s\n", r.Sharpe)
+	fmt.Fprintf(w, "Sortino\t%s\n", r.Sortino)
+	fmt.Fprintf(w, "Calmar\t%s\n", r.Calmar)
+	fmt.Fprintf(w, "Max drawdown\t%s (%.0f days, %s)\n", r.MaxDrawdown.Depth.ToPercent(), r.MaxDrawdown.Duration.Hours()/24, recovered)
+	fmt.Fprintf(w, "Trades\t%d\n", r.Trades)
+	fmt.Fprintf(w, "Hit rate\t%s\n", r.HitRate.ToPercent())
+	fmt.Fprintf(w, "Profit factor\t%s (%s / %s)\n", r.ProfitFactor, Price(r.GrossProfit), Price(r.GrossLoss))
+	w.Flush()
+	return b.String()
+}
+
+// JSON renders a report as indented JSON.
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// ----------------------------------------------------------------------------
+
+// compound returns the total return of a series of periodic returns.
+func compound(returns []float64) float64 {
+	growth := 1.0
+	for _, r := range returns {
+		growth *= 1 + r
+	}
+	return growth - 1
+}
+
+// stdDev returns the sample standard deviation of a series.
+func stdDev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	var mean, ss float64
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	for _, x := range xs {
+		ss += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(ss / float64(len(xs)-1))
+}
+
+// years returns a duration in years of 365.25 days.
+func years(d time.Duration) float64 {
+	return d.Hours() / (24 * 365.25)
+}
+
+// toBps converts a fractional rate to hundredths of a percent.
+func toBps(x float64) Amount {
+	return Amount(math.Round(x * 10000))
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func mockHistory() []Valuation {
+	start := time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)
+	day := func(n int) time.Time { return start.AddDate(0, 0, n) }
+	return []Valuation{
+		{day(0), 100000, 0},
+		{day(30), 105000, 0},
+		{day(60), 98700, 0},
+		{day(90), 112000, 10000},
+		{day(120), 106400, 0},
+		{day(150), 111720, 0},
+		{day(180), 120000, -5000},
+		{day(240), 118800, 0},
+		{day(365), 123600, 0},
+	}
+}
+
+func TestReturns(t *testing.T) {
+	history := mockHistory()
+	reversed := []Valuation{history[1], history[0]}
+	zero := []Valuation{{history[0].Timestamp, 0, 0}, history[1]}
+
+	tests := []struct {
+		name    string
+		history []Valuation
+		wantErr error
+	}{
+		{"base case", history, nil},
+		{"single valuation", history[:1], ErrInsufficientHistory},
+		{"out of order", reversed, ErrOutOfOrder},
+		{"zero value", zero, ErrZeroValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Returns(tt.history)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("Returns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTimeWeightedReturn(t *testing.T) {
+	got, err := TimeWeightedReturn(mockHistory())
+	if err != nil || got != 1725 {
+		t.Errorf("TimeWeightedReturn() = %v, %v, want %v", got.ToPercent(), err, Amount(1725).ToPercent())
+	}
+}
+
+func TestIRR(t *testing.T) {
+	got, err := IRR(mockHistory())
+	if err != nil || got != 1774 {
+		t.Errorf("IRR() = %v, %v, want %v", got.ToPercent(), err, Amount(1774).ToPercent())
+	}
+
+	// Without flows, the money-weighted return over a year is the total return.
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	simple := []Valuation{{start, 100000, 0}, {start.Add(365*24*time.Hour + 6*time.Hour), 90000, 0}}
+	if got, err := IRR(simple); err != nil || got != -1000 {
+		t.Errorf("IRR() = %v, %v, want %v", got.ToPercent(), err, Amount(-1000).ToPercent())
+	}
+
+	// A large loss over a short span annualizes to just above -100%.
+	loss := []Valuation{{start, 100000, 0}, {start.Add(7 * 24 * time.Hour), 50000, 0}}
+	if got, err := IRR(loss); err != nil || got != -10000 {
+		t.Errorf("IRR() = %v, %v, want %v", got.ToPercent(), err, Amount(-10000).ToPercent())
+	}
+}
+
+func TestMaxDrawdown(t *testing.T) {
+	history := mockHistory()
+	got, err := MaxDrawdown(history)
+	if err != nil {
+		t.Fatalf("MaxDrawdown() error = %v", err)
+	}
+	want := Drawdown{
+		Depth:     771,
+		Peak:      history[1].Timestamp,
+		Trough:    history[4].Timestamp,
+		Recovered: history[6].Timestamp,
+		Duration:  150 * 24 * time.Hour,
+	}
+	if got != want {
+		t.Errorf("MaxDrawdown() = %+v, want %+v", got, want)
+	}
+
+	// A drawdown that never recovers lasts until the end of the history.
+	got, _ = MaxDrawdown(history[:5])
+	if !got.Recovered.IsZero() || got.Duration != 90*24*time.Hour {
+		t.Errorf("MaxDrawdown() unrecovered = %+v", got)
+	}
+}
+
+func TestAnalytics_Report(t *testing.T) {
+	trades := []LotRelief{
+		{Cost: 1000, Proceeds: 1500},
+		{Cost: 1000, Proceeds: 800},
+		{Cost: 1000, Proceeds: 1300},
+		{Cost: 1000, Proceeds: 1000},
+	}
+	r, err := NewAnalytics(12).Report(mockHistory(), trades)
+	if err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"total return", r.TotalReturn, Amount(1725)},
+		{"annual return", r.AnnualReturn, Amount(1727)},
+		{"volatility", r.Volatility, Amount(2043)},
+		{"sharpe", r.Sharpe.String(), "1.27"},
+		{"sortino", r.Sortino.String(), "2.69"},
+		{"calmar", r.Calmar.String(), "2.24"},
+		{"hit rate", r.HitRate, Amount(5000)},
+		{"profit factor", r.ProfitFactor.String(), "4.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReport_undefinedIRR(t *testing.T) {
+	// A deposit followed by a total loss has no rate of return.
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []Valuation{{start, 100000, 0}, {start.AddDate(0, 1, 0), 200000, 100000}, {start.AddDate(0, 2, 0), 0, 0}}
+	r, err := NewAnalytics(12).Report(history, nil)
+	if err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if r.IRR != nil {
+		t.Errorf("Report().IRR = %v, want nil", *r.IRR)
+	}
+	if text := r.String(); !strings.Contains(text, "IRR            n/a") {
+		t.Errorf("String() missing undefined IRR in\n%s", text)
+	}
+}
+
+func TestReport_render(t *testing.T) {
+	r, err := NewAnalytics(12).Report(mockHistory(), []LotRelief{{Cost: 1000, Proceeds: 1500}})
+	if err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if r.ProfitFactor.String() != "n/a" || !math.IsInf(float64(r.ProfitFactor), 1) {
+		t.Errorf("ProfitFactor without losses = %v", r.ProfitFactor)
+	}
+
+	text := r.String()
+	for _, want := range []string{"Total return   17.25%", "IRR            17.74%", "Max drawdown   7.71% (150 days, 2017-07-01)", "Profit factor  n/a"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("String() missing %q in\n%s", want, text)
+		}
+	}
+
+	b, err := r.JSON()
+	if err != nil {
+		t.Fatalf("JSON() error = %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("JSON() produced invalid JSON: %v", err)
+	}
+	if decoded["profit_factor"] != nil || decoded["total_return"] != 1725.0 {
+		t.Errorf("JSON() = %s", b)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Strategy reacts to the events of a backtest, submitting orders through it.
+type Strategy interface {
+	// OnQuote is called with each quote, after any orders it fills.
+	OnQuote(bt *Backtest, q *Quote)
+	// OnFill is called with each fill of an order, after it is applied to the portfolio.
+	OnFill(bt *Backtest, f *Fill)
+	// OnBar is called with each completed bar, before the quote that completed it.
+	OnBar(bt *Backtest, b Bar)
+}
+
+// Trade is a fill executed by a backtest, along with the lots it relieved.
+type Trade struct {
+	Fill
+	Reliefs []LotRelief
+}
+
+// Backtest replays quotes in timestamp order through a Strategy, filling the orders it
+// submits against later quotes with a FillSimulator and applying the fills to a Portfolio.
+//
+// Orders are only considered for quotes after the one they were submitted on, so a strategy
+// never trades on a quote it has just seen, and are matched in the order they were submitted.
+// Each fill consumes the volume it takes from the quote. When BarInterval is set, quote
+// midpoints are aggregated into time bars of that interval per name, aligned to Session.
+// Equity records the portfolio's NAV as of each distinct quote timestamp. When Registry
+// is set, orders are rejected unless they conform to their instrument's tick and lot sizes.
+//
+// A backtest is deterministic: replaying the same quotes through the same strategy
+// produces the same trades and equity curve.
+type Backtest struct {
+	Strategy    Strategy
+	Portfolio   *Portfolio
+	Fills       *FillSimulator
+	BarInterval time.Duration
+	Session     Session
+	Registry    *Registry
+	Equity      []Valuation
+	Trades      []Trade
+
+	orders []*Order
+	marks  map[string]Quote
+	bars   map[string]*BarBuilder
+	ready  []Bar
+	now    time.Time
+}
+
+// NewBacktest returns a new backtest of a strategy over a portfolio funded with an amount of cash.
+// A nil fill simulator fills every order at the touch.
+func NewBacktest(strategy Strategy, cash Amount, fills *FillSimulator) *Backtest {
+	if fills == nil {
+		fills = NewFillSimulator(nil)
+	}
+	return &Backtest{
+		Strategy:  strategy,
+		Portfolio: NewPortfolio(cash),
+		Fills:     fills,
+		marks:     make(map[string]Quote),
+		bars:      make(map[string]*BarBuilder),
+	}
+}
+
+// Now returns the timestamp of the quote being replayed.
+func (bt *Backtest) Now() time.Time {
+	return bt.now
+}
+
+// Mark returns the latest quote seen for a name.
+func (bt *Backtest) Mark(name string) (Quote, bool) {
+	q, ok := bt.marks[name]
+	return q, ok
+}
+
+// Submit an order to a backtest, to be filled against subsequent quotes of its name.
+func (bt *Backtest) Submit(o *Order) error {
+	if o.Status != Open || o.Remaining() == 0 || (o.Logic == Limit && o.Price <= 0) {
+		return errors.Wrap(ErrInvalidOrder, o.Name)
+	}
+	if bt.Registry != nil {
+		if err := bt.Registry.ValidateOrder(o); err != nil {
+			return err
+		}
+	}
+	bt.orders = append(bt.orders, o)
+	return nil
+}
+
+// Market submits a market order for a volume of a name, timestamped now.
+func (bt *Backtest) Market(name string, buy bool, vol Volume) (*Order, error) {
+	o := NewOrder(name, buy, Market, 0, vol, bt.now)
+	return o, bt.Submit(o)
+}
+
+// Limit submits a limit order for a volume of a name, timestamped now.
+func (bt *Backtest) Limit(name string, buy bool, price Price, vol Volume) (*Order, error) {
+	o := NewOrder(name, buy, Limit, price, vol, bt.now)
+	return o, bt.Submit(o)
+}
+
+// Cancel an open order.
+func (bt *Backtest) Cancel(o *Order) {
+	if o.Status == Open {
+		o.Status = Cancelled
+	}
+}
+
+// Orders returns a backtest's open orders in the order they were submitted.
+func (bt *Backtest) Orders() []*Order {
+	open := bt.orders[:0]
+	for _, o := range bt.orders {
+		if o.Status == Open {
+			open = append(open, o)
+		}
+	}
+	bt.orders = open
+	return append([]*Order(nil), open...)
+}
+
+// Run replays quotes through a backtest in timestamp order, keeping quotes that share a
+// timestamp in the order given, then flushes any incomplete bars.
+func (bt *Backtest) Run(quotes []Quote) error {
+	sorted := append([]Quote(nil), quotes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	for i := range sorted {
+		if err := bt.Step(sorted[i]); err != nil {
+			return err
+		}
+	}
+	bt.Flush()
+	return nil
+}
+
+// Step replays a single quote through a backtest.
+// Quotes must be stepped through in timestamp order.
+func (bt *Backtest) Step(q Quote) error {
+	if q.Timestamp.Before(bt.now) {
+		return errors.Wrapf(ErrOutOfOrder, "quote at %s", q.Timestamp)
+	}
+	bt.now = q.Timestamp
+
+	if bt.BarInterval != 0 {
+		// Quotes missing a side or outside the session do not form bars.
+		_ = bt.barBuilder(q.Name).AddQuote(&q)
+		bt.emitBars()
+	}
+	if err := bt.match(q); err != nil {
+		return err
+	}
+	if _, err := markPrice(q); err == nil {
+		bt.marks[q.Name] = q
+	}
+	bt.Strategy.OnQuote(bt, &q)
+	return bt.record()
+}
+
+// Flush completes the bars in progress for every name, in name order.
+func (bt *Backtest) Flush() {
+	names := make([]string, 0, len(bt.bars))
+	for name := range bt.bars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		bt.bars[name].Flush()
+	}
+	bt.emitBars()
+}
+
+// Reliefs returns the lot reliefs of every trade in a backtest, in the order they were made.
+func (bt *Backtest) Reliefs() []LotRelief {
+	var reliefs []LotRelief
+	for _, t := range bt.Trades {
+		reliefs = append(reliefs, t.Reliefs...)
+	}
+	return reliefs
+}
+
+// Report analyzes the equity curve and closed trades of a backtest.
+func (bt *Backtest) Report(a *Analytics) (*Report, error) {
+	return a.Report(bt.Equity, bt.Reliefs())
+}
+
+// match fills the open orders of a quote's name against it, in the order they were submitted.
+func (bt *Backtest) match(q Quote) error {
+	for _, o := range bt.Orders() {
+		if o.Name != q.Name || o.Status != Open {
+			continue
+		}
+		fill, err := bt.Fills.Fill(o, &q)
+		switch {
+		case errors.Cause(err) == ErrNoLiquidity:
+			continue
+		case err != nil:
+			return err
+		case fill == nil:
+			continue
+		}
+		if o.Buy {
+			q.Ask.Volume -= fill.Volume
+		} else {
+			q.Bid.Volume -= fill.Volume
+		}
+
+		reliefs, err := bt.Portfolio.Apply(fill.Transaction)
+		if err != nil {
+			return errors.Wrapf(err, "applying fill of %s", o.Name)
+		}
+		bt.Trades = append(bt.Trades, Trade{Fill: *fill, Reliefs: reliefs})
+		bt.Strategy.OnFill(bt, fill)
+	}
+	return nil
+}
+
+// record appends the portfolio's NAV to the equity curve, replacing the last
+// point if it shares the current timestamp.
+func (bt *Backtest) record() error {
+	v, err := bt.Portfolio.Valuation(bt.now, bt.marks)
+	if err != nil {
+		return err
+	}
+	if n := len(bt.Equity); n != 0 && bt.Equity[n-1].Timestamp.Equal(bt.now) {
+		bt.Equity[n-1] = v
+		return nil
+	}
+	bt.Equity = append(bt.Equity, v)
+	return nil
+}
+
+// barBuilder returns the time bar builder of a name, creating it if needed.
+func (bt *Backtest) barBuilder(name string) *BarBuilder {
+	b, ok := bt.bars[name]
+	if !ok {
+		b = NewTimeBars(bt.BarInterval, bt.Session, func(bar Bar) {
+			bt.ready = append(bt.ready, bar)
+		})
+		bt.bars[name] = b
+	}
+	return b
+}
+
+// emitBars passes completed bars to the strategy in the order they completed.
+func (bt *Backtest) emitBars() {
+	for len(bt.ready) != 0 {
+		bar := bt.ready[0]
+		bt.ready = bt.ready[1:]
+		bt.Strategy.OnBar(bt, bar)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// mockStrategy buys on its first quote and sells once the bid has risen a dollar, logging every event.
+type mockStrategy struct {
+	events []string
+	entry  Price
+}
+
+func (s *mockStrategy) OnQuote(bt *Backtest, q *Quote) {
+	s.events = append(s.events, "quote "+q.Timestamp.Format("15:04:05"))
+	h, held := bt.Portfolio.Holdings[q.Name]
+	switch {
+	case len(bt.Orders()) != 0:
+	case !held && s.entry == 0:
+		bt.Market(q.Name, true, 100)
+	case held && h.Volume != 0 && q.Bid.Price >= s.entry+100:
+		bt.Market(q.Name, false, h.Volume)
+	}
+}
+
+func (s *mockStrategy) OnFill(bt *Backtest, f *Fill) {
+	if f.Buy {
+		s.entry = f.Price
+	}
+	s.events = append(s.events, fmt.Sprintf("fill %v %d @ %s", f.Buy, f.Volume, f.Price))
+}
+
+func (s *mockStrategy) OnBar(bt *Backtest, b Bar) {
+	s.events = append(s.events, "bar "+b.Start.Format("15:04"))
+}
+
+func mockBacktestQuotes() []Quote {
+	at := func(min, sec int) time.Time { return time.Date(2017, 1, 3, 9, 30+min, sec, 0, time.UTC) }
+	quote := func(bid, ask Price, t time.Time) Quote {
+		return Quote{Name: "AAPL", Bid: QuotedMetric{bid, 100}, Ask: QuotedMetric{ask, 100}, Timestamp: t}
+	}
+	// Given out of order to exercise sorting.
+	return []Quote{
+		quote(10100, 10150, at(0, 30)),
+		quote(9900, 10000, at(0, 0)),
+		quote(10150, 10200, at(1, 10)),
+		quote(10200, 10250, at(2, 0)),
+	}
+}
+
+func runMockBacktest(t *testing.T) (*Backtest, *mockStrategy) {
+	s := &mockStrategy{}
+	bt := NewBacktest(s, 2000000, nil)
+	bt.BarInterval = time.Minute
+	bt.Session = Session{Open: 9*time.Hour + 30*time.Minute, Close: 16 * time.Hour, Location: time.UTC}
+	if err := bt.Run(mockBacktestQuotes()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	return bt, s
+}
+
+func TestBacktest_Run(t *testing.T) {
+	bt, s := runMockBacktest(t)
+
+	wantEvents := []string{
+		"quote 09:30:00",
+		"fill true 100 @ $101.50",
+		"quote 09:30:30",
+		"bar 09:30",
+		"quote 09:31:10",
+		"bar 09:31",
+		"quote 09:32:00",
+		"bar 09:32",
+	}
+	if !reflect.DeepEqual(s.events, wantEvents) {
+		t.Errorf("events = %q, want %q", s.events, wantEvents)
+	}
+
+	var equity []Amount
+	for _, v := range bt.Equity {
+		equity = append(equity, v.Value)
+	}
+	if want := []Amount{2000000, 1997500, 2002500, 2007500}; !reflect.DeepEqual(equity, want) {
+		t.Errorf("equity = %v, want %v", equity, want)
+	}
+	if len(bt.Trades) != 1 || len(bt.Orders()) != 0 {
+		t.Errorf("trades = %d, open orders = %d, want 1 and 0", len(bt.Trades), len(bt.Orders()))
+	}
+}
+
+func TestBacktest_roundTrip(t *testing.T) {
+	s := &mockStrategy{}
+	bt := NewBacktest(s, 2000000, nil)
+	quotes := mockBacktestQuotes()
+	for i, bid := range []Price{10300, 10350} {
+		quotes = append(quotes, Quote{
+			Name: "AAPL", Bid: QuotedMetric{bid, 100}, Ask: QuotedMetric{bid + 50, 100},
+			Timestamp: time.Date(2017, 1, 3, 9, 33+i, 0, 0, time.UTC),
+		})
+	}
+	if err := bt.Run(quotes); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	reliefs := bt.Reliefs()
+	if len(bt.Trades) != 2 || len(reliefs) != 1 || reliefs[0].Gain() != 20000 {
+		t.Fatalf("trades = %+v, want a round trip gaining $200.00", bt.Trades)
+	}
+	if got := bt.Equity[len(bt.Equity)-1].Value; got != bt.Portfolio.Cash || got != 2020000 {
+		t.Errorf("final equity = %v, cash = %v, want %v", got, bt.Portfolio.Cash, Amount(2020000))
+	}
+}
+
+func TestBacktest_deterministic(t *testing.T) {
+	a, sa := runMockBacktest(t)
+	b, sb := runMockBacktest(t)
+	if !reflect.DeepEqual(a.Equity, b.Equity) || !reflect.DeepEqual(a.Trades, b.Trades) || !reflect.DeepEqual(sa.events, sb.events) {
+		t.Errorf("repeated backtests diverged")
+	}
+}
+
+func TestBacktest_Step(t *testing.T) {
+	bt := NewBacktest(&mockStrategy{}, 0, nil)
+	start := time.Date(2017, 1, 3, 9, 30, 0, 0, time.UTC)
+	q := Quote{Name: "AAPL", Bid: QuotedMetric{9900, 100}, Ask: QuotedMetric{10000, 100}, Timestamp: start}
+
+	// Limit orders fill in submission order, each consuming the quote's volume.
+	first := NewOrder("AAPL", true, Limit, 9950, 80, start)
+	second := NewOrder("AAPL", true, Limit, 9950, 80, start)
+	bt.Submit(first)
+	bt.Submit(second)
+	if err := bt.Step(q); err != nil || len(bt.Trades) != 0 {
+		t.Fatalf("Step() filled limit orders above their price: %v, %+v", err, bt.Trades)
+	}
+
+	q.Ask.Price, q.Timestamp = 9940, start.Add(time.Second)
+	if err := bt.Step(q); err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if len(bt.Trades) != 2 || bt.Trades[0].Volume != 80 || bt.Trades[1].Volume != 20 || second.Remaining() != 60 {
+		t.Errorf("Step() trades = %+v, want fills of 80 and 20", bt.Trades)
+	}
+
+	q.Timestamp = start
+	if err := bt.Step(q); errors.Cause(err) != ErrOutOfOrder {
+		t.Errorf("Step() error = %v, want %v", err, ErrOutOfOrder)
+	}
+	if err := bt.Submit(first); errors.Cause(err) != ErrInvalidOrder {
+		t.Errorf("Submit() of filled order error = %v, want %v", err, ErrInvalidOrder)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrOutsideSession is returned when a time bar builder receives a tick outside its trading session.
+var ErrOutsideSession = errors.New("tick outside of trading session")
+
+// Bar is an open-high-low-close summary of the ticks of a security over a period.
+// Value is the total amount traded, and Ticks is the number of ticks aggregated.
+type Bar struct {
+	Name                   string
+	Open, High, Low, Close Price
+	Volume                 Volume
+	Value                  Amount
+	Ticks                  int
+	Start, End             time.Time
+}
+
+// BarType refers to the rule used to decide when a bar is complete.
+type BarType int
+
+const (
+	// TimeBars are complete at the end of each fixed interval of time.
+	TimeBars BarType = iota // 0
+	// TickBars are complete after a number of ticks.
+	TickBars
+	// VolumeBars are complete once a volume has traded.
+	VolumeBars
+	// DollarBars are complete once an amount has traded.
+	DollarBars
+)
+
+// Session is a daily trading session, given as offsets from midnight in a location.
+// A zero Close leaves the session open all day.
+type Session struct {
+	Open, Close time.Duration
+	Location    *time.Location
+}
+
+// ----------------------------------------------------------------------------
+
+// BarBuilder aggregates a stream of quotes or transactions into bars, passing each
+// completed bar to OnBar.
+//
+// Time bars are aligned to the open of their session. When GapFill is set, intervals
+// within the session that see no ticks produce flat, empty bars at the previous close.
+// Tick, volume and dollar bars include the whole of the tick that reaches their
+// threshold, rather than splitting it across bars.
+type BarBuilder struct {
+	Type      BarType
+	Interval  time.Duration
+	Threshold int64
+	Session   Session
+	GapFill   bool
+	OnBar     func(Bar)
+
+	bar  *Bar
+	last time.Time
+}
+
+// NewTimeBars returns a builder of bars spanning an interval of time, aligned to a session.
+func NewTimeBars(interval time.Duration, session Session, onBar func(Bar)) *BarBuilder {
+	return &BarBuilder{Type: TimeBars, Interval: interval, Session: session, OnBar: onBar}
+}
+
+// NewTickBars returns a builder of bars made of a number of ticks.
+func NewTickBars(ticks int, onBar func(Bar)) *BarBuilder {
+	return &BarBuilder{Type: TickBars, Threshold: int64(ticks), OnBar: onBar}
+}
+
+// NewVolumeBars returns a builder of bars closed once a volume has traded.
+func NewVolumeBars(vol Volume, onBar func(Bar)) *BarBuilder {
+	return &BarBuilder{Type: VolumeBars, Threshold: int64(vol), OnBar: onBar}
+}
+
+// NewDollarBars returns a builder of bars closed once an amount has traded.
+func NewDollarBars(amt Amount, onBar func(Bar)) *BarBuilder {
+	return &BarBuilder{Type: DollarBars, Threshold: int64(amt), OnBar: onBar}
+}
+
+// BarChannel returns an OnBar callback that sends each bar to a channel.
+func BarChannel(ch chan<- Bar) func(Bar) {
+	return func(b Bar) {
+		ch <- b
+	}
+}
+
+// AddQuote adds a quote to a bar builder as a tick at its midpoint.
+// Quotes carry no traded volume, so they only advance time and tick bars.
+func (b *BarBuilder) AddQuote(q *Quote) error {
+	mid, err := q.Mid()
+	if err != nil {
+		return err
+	}
+	return b.Add(q.Name, mid, 0, q.Timestamp)
+}
+
+// AddTransaction adds a transaction to a bar builder as a tick.
+func (b *BarBuilder) AddTransaction(tx *Transaction) error {
+	return b.Add(tx.Name, tx.Price, tx.Volume, tx.Timestamp)
+}
+
+// Add a tick of a price and volume at a time to a bar builder, emitting any bars it completes.
+func (b *BarBuilder) Add(name string, p Price, vol Volume, t time.Time) error {
+	if t.Before(b.last) {
+		return errors.Wrap(ErrOutOfOrder, t.String())
+	}
+	if b.bar != nil && b.bar.Name != name {
+		return errors.Wrapf(ErrInvalidTx, "wanted %s, got %s", b.bar.Name, name)
+	}
+
+	if b.Type == TimeBars {
+		start, ok := b.bucket(t)
+		if !ok {
+			return errors.Wrap(ErrOutsideSession, t.String())
+		}
+		if b.bar != nil && !start.Equal(b.bar.Start) {
+			b.rollTo(start)
+		}
+		if b.bar == nil {
+			b.bar = &Bar{Name: name, Open: p, High: p, Low: p, Start: start, End: start.Add(b.Interval)}
+		}
+	} else if b.bar == nil {
+		b.bar = &Bar{Name: name, Open: p, High: p, Low: p, Start: t}
+	}
+	b.last = t
+
+	bar := b.bar
+	if p > bar.High {
+		bar.High = p
+	}
+	if p < bar.Low {
+		bar.Low = p
+	}
+	bar.Close = p
+	bar.Volume += vol
+	bar.Value += NewAmount(p, vol)
+	bar.Ticks++
+	if b.Type != TimeBars {
+		bar.End = t
+	}
+
+	if b.full() {
+		b.Flush()
+	}
+	return nil
+}
+
+// Flush emits the bar currently being built, if it has received any ticks.
+func (b *BarBuilder) Flush() {
+	if b.bar == nil {
+		return
+	}
+	bar := *b.bar
+	b.bar = nil
+	if b.OnBar != nil {
+		b.OnBar(bar)
+	}
+}
+
+// full reports whether the bar being built has reached its threshold.
+func (b *BarBuilder) full() bool {
+	switch b.Type {
+	case TickBars:
+		return int64(b.bar.Ticks) >= b.Threshold
+	case VolumeBars:
+		return int64(b.bar.Volume) >= b.Threshold
+	case DollarBars:
+		return int64(b.bar.Value) >= b.Threshold
+	}
+	return false
+}
+
+// rollTo emits the current time bar, and empty bars for any skipped intervals
+// within the session if GapFill is set, up to a new bar's start.
+func (b *BarBuilder) rollTo(start time.Time) {
+	prev := *b.bar
+	b.Flush()
+	if !b.GapFill {
+		return
+	}
+	for s := prev.End; s.Before(start); s = s.Add(b.Interval) {
+		if bucket, ok := b.bucket(s); !ok || !bucket.Equal(s) {
+			continue
+		}
+		b.bar = &Bar{
+			Name: prev.Name, Open: prev.Close, High: prev.Close, Low: prev.Close, Close: prev.Close,
+			Start: s, End: s.Add(b.Interval),
+		}
+		b.Flush()
+	}
+}
+
+// bucket returns the start of the time bar containing a time,
+// and whether the time falls within the builder's session.
+func (b *BarBuilder) bucket(t time.Time) (time.Time, bool) {
+	loc := b.Session.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	open := midnight.Add(b.Session.Open)
+	if local.Before(open) {
+		midnight = midnight.AddDate(0, 0, -1)
+		open = midnight.Add(b.Session.Open)
+	}
+	if b.Session.Close != 0 && !local.Before(midnight.Add(b.Session.Close)) {
+		return time.Time{}, false
+	}
+	return open.Add(local.Sub(open) / b.Interval * b.Interval), true
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func mockBarTx(price float64, vol uint32, t time.Time) *Transaction {
+	return &Transaction{Name: "AAPL", Buy: true, QuotedMetric: NewQuotedMetric(price, vol), Timestamp: t}
+}
+
+func TestBarBuilder_timeBars(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database unavailable")
+	}
+	at := func(h, m int) time.Time { return time.Date(2017, 1, 3, h, m, 0, 0, ny) }
+	session := Session{Open: 9*time.Hour + 30*time.Minute, Close: 16 * time.Hour, Location: ny}
+	txs := []*Transaction{
+		mockBarTx(10.00, 100, at(9, 31)),
+		mockBarTx(10.50, 200, at(9, 40)),
+		mockBarTx(9.80, 100, at(10, 5)),
+		mockBarTx(10.25, 300, at(11, 5)),
+	}
+	bar := func(o, h, l, c float64, vol uint32, value Amount, ticks int, start time.Time) Bar {
+		return Bar{"AAPL", NewPrice(o), NewPrice(h), NewPrice(l), NewPrice(c), NewVolume(vol), value, ticks, start, start.Add(30 * time.Minute)}
+	}
+	first := bar(10.00, 10.50, 10.00, 10.50, 300, 100000+210000, 2, at(9, 30))
+	second := bar(9.80, 9.80, 9.80, 9.80, 100, 98000, 1, at(9, 30).Add(30*time.Minute))
+	last := bar(10.25, 10.25, 10.25, 10.25, 300, 307500, 1, at(11, 0))
+
+	tests := []struct {
+		name    string
+		gapFill bool
+		want    []Bar
+	}{
+		{"gaps", false, []Bar{first, second, last}},
+		{"gap filled", true, []Bar{first, second,
+			bar(9.80, 9.80, 9.80, 9.80, 0, 0, 0, at(10, 30)), last}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Bar
+			b := NewTimeBars(30*time.Minute, session, func(bar Bar) { got = append(got, bar) })
+			b.GapFill = tt.gapFill
+			for _, tx := range txs {
+				if err := b.AddTransaction(tx); err != nil {
+					t.Fatalf("BarBuilder.AddTransaction() error = %v", err)
+				}
+			}
+			b.Flush()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BarBuilder bars = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	b := NewTimeBars(30*time.Minute, session, nil)
+	if err := b.AddTransaction(mockBarTx(10.00, 100, at(16, 1))); errors.Cause(err) != ErrOutsideSession {
+		t.Errorf("BarBuilder.AddTransaction() error = %v, want %v", err, ErrOutsideSession)
+	}
+	b.AddTransaction(mockBarTx(10.00, 100, at(10, 0)))
+	if err := b.AddTransaction(mockBarTx(10.00, 100, at(9, 45))); errors.Cause(err) != ErrOutOfOrder {
+		t.Errorf("BarBuilder.AddTransaction() error = %v, want %v", err, ErrOutOfOrder)
+	}
+}
+
+func TestBarBuilder_dailyBars(t *testing.T) {
+	var got []Bar
+	b := NewTimeBars(24*time.Hour, Session{}, func(bar Bar) { got = append(got, bar) })
+	day := func(d, h int) time.Time { return time.Date(2017, 1, d, h, 0, 0, 0, time.UTC) }
+	for _, tx := range []*Transaction{mockBarTx(10, 1, day(3, 10)), mockBarTx(11, 1, day(3, 15)), mockBarTx(12, 1, day(4, 10))} {
+		b.AddTransaction(tx)
+	}
+	b.Flush()
+
+	if len(got) != 2 || !got[0].Start.Equal(day(3, 0)) || got[0].Close != NewPrice(11) || !got[1].Start.Equal(day(4, 0)) {
+		t.Errorf("BarBuilder daily bars = %+v", got)
+	}
+}
+
+func TestBarBuilder_thresholdBars(t *testing.T) {
+	start := time.Date(2017, 1, 3, 9, 30, 0, 0, time.UTC)
+	txs := []*Transaction{
+		mockBarTx(10.00, 100, start),
+		mockBarTx(11.00, 300, start.Add(time.Second)),
+		mockBarTx(9.00, 100, start.Add(2*time.Second)),
+		mockBarTx(10.00, 500, start.Add(3*time.Second)),
+	}
+
+	tests := []struct {
+		name      string
+		b         func(func(Bar)) *BarBuilder
+		wantTicks []int
+		wantClose []Price
+	}{
+		{"tick", func(f func(Bar)) *BarBuilder { return NewTickBars(2, f) }, []int{2, 2}, []Price{1100, 1000}},
+		{"volume", func(f func(Bar)) *BarBuilder { return NewVolumeBars(400, f) }, []int{2, 2}, []Price{1100, 1000}},
+		{"dollar", func(f func(Bar)) *BarBuilder { return NewDollarBars(NewAmount(NewPrice(1000.00), 1), f) }, []int{1, 1, 2}, []Price{1000, 1100, 1000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan Bar, 4)
+			b := tt.b(BarChannel(ch))
+			for _, tx := range txs {
+				b.AddTransaction(tx)
+			}
+			b.Flush()
+			close(ch)
+
+			var ticks []int
+			var closes []Price
+			for bar := range ch {
+				ticks = append(ticks, bar.Ticks)
+				closes = append(closes, bar.Close)
+			}
+			if !reflect.DeepEqual(ticks, tt.wantTicks) || !reflect.DeepEqual(closes, tt.wantClose) {
+				t.Errorf("BarBuilder ticks = %v closes = %v, want %v and %v", ticks, closes, tt.wantTicks, tt.wantClose)
+			}
+		})
+	}
+}
+
+func TestBarBuilder_AddQuote(t *testing.T) {
+	var got []Bar
+	b := NewTickBars(2, func(bar Bar) { got = append(got, bar) })
+	q := mockSpreadQuote()
+	b.AddQuote(q)
+	q.Bid.Price, q.Ask.Price = NewPrice(101.00), NewPrice(102.00)
+	b.AddQuote(q)
+
+	if len(got) != 1 || got[0].Open != NewPrice(99.50) || got[0].Close != NewPrice(101.50) || got[0].Volume != 0 {
+		t.Errorf("BarBuilder quote bars = %+v", got)
+	}
+	if err := b.AddQuote(&Quote{Name: "AAPL"}); err != ErrNilValue {
+		t.Errorf("BarBuilder.AddQuote() error = %v, want %v", err, ErrNilValue)
+	}
+}
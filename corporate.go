@@ -0,0 +1,430 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidAction is returned when a corporate action is malformed or cannot be applied.
+var ErrInvalidAction = errors.New("invalid corporate action")
+
+// ActionType refers to the kind of a corporate action.
+type ActionType int
+
+const (
+	// Split issues New shares for every Old share held; a reverse split has fewer New than Old.
+	Split ActionType = iota // 0
+	// StockDividend issues shares as a dividend, expressed as New shares for every Old held,
+	// such as 105 for 100 for a 5% dividend.
+	StockDividend
+	// CashDividend pays Amount per share held.
+	CashDividend
+	// SpinOff issues New shares of NewName for every Old share held, moving CostFraction
+	// of each lot's basis into the new shares.
+	SpinOff
+	// Rename changes a security's name to NewName.
+	Rename
+)
+
+// CorporateAction is an event that changes the shares or value of a security, effective
+// on its ExDate. Fractional shares resulting from an action are cashed out at Price,
+// the price of the security receiving them after the action.
+type CorporateAction struct {
+	Type         ActionType
+	Name         string
+	ExDate       time.Time
+	New, Old     int
+	Amount       Price
+	Price        Price
+	NewName      string
+	CostFraction float64
+}
+
+// validate checks that a corporate action has the fields its type requires.
+func (a CorporateAction) validate() error {
+	switch {
+	case a.Name == "":
+		return errors.Wrap(ErrInvalidAction, "no name")
+	case (a.Type == Split || a.Type == StockDividend || a.Type == SpinOff) && (a.New <= 0 || a.Old <= 0):
+		return errors.Wrapf(ErrInvalidAction, "%s ratio %d for %d", a.Name, a.New, a.Old)
+	case (a.Type == SpinOff || a.Type == Rename) && (a.NewName == "" || a.NewName == a.Name):
+		return errors.Wrapf(ErrInvalidAction, "%s new name %q", a.Name, a.NewName)
+	case a.Type == SpinOff && (a.CostFraction < 0 || a.CostFraction > 1):
+		return errors.Wrapf(ErrInvalidAction, "%s cost fraction %v", a.Name, a.CostFraction)
+	case a.Type < Split || a.Type > Rename:
+		return errors.Wrapf(ErrInvalidAction, "%s type %d", a.Name, a.Type)
+	}
+	return nil
+}
+
+// ActionResult is the effect of a corporate action on a holding's cash and basis.
+// Basis amounts are signed as in the securities account: positive for long holdings and
+// negative for short holdings.
+type ActionResult struct {
+	// Cash is paid into the portfolio, or out of it if negative.
+	Cash Amount
+	// Moved is basis moved from the action's Name to its NewName.
+	Moved Amount
+	// Relieved is basis relieved from fractional shares cashed out, realizing Gain.
+	Relieved Amount
+	Gain     Amount
+}
+
+// ----------------------------------------------------------------------------
+
+// Split multiplies the volume of each of a holding's lots by a ratio of new shares for old,
+// leaving their basis unchanged. Fractional shares are cashed out at a price, realizing a
+// gain or loss against their share of the lot's basis.
+func (h *Holding) Split(newShares, oldShares int, price Price) ActionResult {
+	var r ActionResult
+	h.ensureLots()
+	n, o := Amount(newShares), Amount(oldShares)
+
+	lots := h.Lots[:0]
+	var volume Volume
+	for _, lot := range h.Lots {
+		scaled := Amount(lot.Volume) * n
+		if frac := scaled % o; frac != 0 {
+			basis := lot.Cost * frac / scaled
+			cash := roundDiv(Amount(price)*frac, o)
+			lot.Cost -= basis
+			h.Cost -= basis
+			if h.Short {
+				basis, cash = -basis, -cash
+			}
+			r.Cash += cash
+			r.Relieved += basis
+			r.Gain += cash - basis
+		}
+		lot.Volume = Volume(scaled / o)
+		lot.Price = Price(roundDiv(Amount(lot.Price)*o, n))
+		if lot.Volume != 0 {
+			lots = append(lots, lot)
+			volume += lot.Volume
+		}
+	}
+	h.Lots = lots
+	h.Volume = volume
+	h.realized += r.Gain
+
+	entry, exit := h.entry(), h.exit()
+	entry.Price = Price(roundDiv(Amount(entry.Price)*o, n))
+	if h.Volume != 0 {
+		entry.Price = h.lotPrice()
+	}
+	exit.Price = Price(roundDiv(Amount(exit.Price)*o, n))
+	h.exited = Volume(Amount(h.exited) * n / o)
+	return r
+}
+
+// Dividend credits a holding with a cash dividend per share, or debits it for a short
+// holding, which owes the dividend to the lender of its shares. It returns the amount paid.
+func (h *Holding) Dividend(perShare Price) Amount {
+	amt := NewAmount(perShare, h.Volume)
+	if h.Short {
+		amt = -amt
+	}
+	h.Dividends += amt
+	h.realized += amt
+	return amt
+}
+
+// SpinOff moves part of the basis of each of a holding's lots into a new holding of the
+// spun-off security, keeping each lot's acquisition date.
+func (h *Holding) SpinOff(a CorporateAction) (*Holding, ActionResult, error) {
+	if err := a.validate(); err != nil {
+		return nil, ActionResult{}, err
+	}
+	if a.Type != SpinOff || a.Name != h.Name {
+		return nil, ActionResult{}, errors.Wrapf(ErrInvalidAction, "spin-off of %s from %s", a.Name, h.Name)
+	}
+	h.ensureLots()
+	spun := &Holding{Name: a.NewName, Short: h.Short, Method: h.Method}
+	*spun.entry() = TxMetric{Date: h.entry().Date}
+
+	var moved Amount
+	for i := range h.Lots {
+		lot := &h.Lots[i]
+		basis := Amount(math.Round(float64(lot.Cost) * a.CostFraction))
+		lot.Cost -= basis
+		moved += basis
+
+		spun.lotSeq++
+		spun.Lots = append(spun.Lots, Lot{
+			ID:     spun.lotSeq,
+			Volume: lot.Volume,
+			Price:  Price(roundDiv(basis, Amount(lot.Volume))),
+			Cost:   basis,
+			Date:   lot.Date,
+		})
+	}
+	h.Cost -= moved
+	h.entry().Price = h.lotPrice()
+
+	spun.Volume, spun.Cost = h.Volume, moved
+	if h.invested != 0 {
+		spun.invested = h.invested * moved / (h.Cost + moved)
+		h.invested -= spun.invested
+	}
+	r := spun.Split(a.New, a.Old, a.Price)
+	r.Moved = moved
+	if h.Short {
+		r.Moved = -moved
+	}
+	return spun, r, nil
+}
+
+// absorb merges the lots of another holding on the same side into a holding, along with
+// its realized P&L, fees, dividends and borrow costs.
+func (h *Holding) absorb(o *Holding) error {
+	if h.Volume != 0 && o.Volume != 0 && h.Short != o.Short {
+		return errors.Wrapf(ErrInvalidAction, "cannot merge long and short holdings of %s", h.Name)
+	}
+	h.ensureLots()
+	if h.Volume == 0 {
+		h.Short = o.Short
+		*h.entry() = *o.entry()
+	}
+	for _, lot := range o.Lots {
+		h.lotSeq++
+		lot.ID = h.lotSeq
+		h.Lots = append(h.Lots, lot)
+	}
+	h.Volume += o.Volume
+	h.Cost += o.Cost
+	h.invested += o.invested
+	h.Fees += o.Fees
+	h.Borrow += o.Borrow
+	h.Dividends += o.Dividends
+	h.realized += o.realized
+	h.entry().Price = h.lotPrice()
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// ApplyAction applies a corporate action to a portfolio's holding of its Name, if any,
+// crediting or debiting cash for dividends and fractional shares. Spun-off and renamed
+// holdings are merged into any holding the portfolio already has of their new name.
+func (p *Portfolio) ApplyAction(a CorporateAction) (ActionResult, error) {
+	var r ActionResult
+	if err := a.validate(); err != nil {
+		return r, err
+	}
+	h, ok := p.Holdings[a.Name]
+	if !ok {
+		return r, nil
+	}
+
+	switch a.Type {
+	case Split, StockDividend:
+		r = h.Split(a.New, a.Old, a.Price)
+	case CashDividend:
+		r.Cash = h.Dividend(a.Amount)
+	case SpinOff:
+		// Spin off from a copy, so that the parent is unchanged if the merge fails.
+		parent := h.Clone()
+		spun, res, err := parent.SpinOff(a)
+		if err != nil {
+			return r, err
+		}
+		if err := p.merge(spun); err != nil {
+			return r, err
+		}
+		*h, r = *parent, res
+	case Rename:
+		r.Moved = h.Cost
+		if h.Short {
+			r.Moved = -h.Cost
+		}
+		delete(p.Holdings, a.Name)
+		h.Name = a.NewName
+		if err := p.merge(h); err != nil {
+			p.Holdings[a.Name], h.Name = h, a.Name
+			return ActionResult{}, err
+		}
+	}
+	p.Cash += r.Cash
+	return r, nil
+}
+
+// merge adds a holding to a portfolio, merging it into any holding of the same name.
+func (p *Portfolio) merge(h *Holding) error {
+	existing, ok := p.Holdings[h.Name]
+	if !ok {
+		if p.Holdings == nil {
+			p.Holdings = make(map[string]*Holding)
+		}
+		p.Holdings[h.Name] = h
+		return nil
+	}
+	return existing.absorb(h)
+}
+
+// RecordAction journals the result of a corporate action applied to a portfolio.
+// Dividends are recorded as income, basis moved by spin-offs and renames is transferred
+// between securities accounts, and fractional shares cashed out realize P&L.
+func (l *Ledger) RecordAction(a CorporateAction, r ActionResult) error {
+	if a.Type == CashDividend {
+		if r.Cash == 0 {
+			return nil
+		}
+		return l.RecordDividend(a.Name, r.Cash, a.ExDate)
+	}
+	target := a.Name
+	if a.Type == SpinOff || a.Type == Rename {
+		target = a.NewName
+	}
+	return l.Post(Entry{Timestamp: a.ExDate, Memo: actionMemo(a), Postings: []Posting{
+		{SecuritiesAccount.Sub(a.Name), -r.Moved},
+		{SecuritiesAccount.Sub(target), r.Moved},
+		{CashAccount, r.Cash},
+		{SecuritiesAccount.Sub(target), -r.Relieved},
+		{PnLAccount, -r.Gain},
+	}})
+}
+
+// actionMemo describes a corporate action for the memo of its journal entry.
+func actionMemo(a CorporateAction) string {
+	switch a.Type {
+	case SpinOff:
+		return "spin-off " + a.NewName + " from " + a.Name
+	case Rename:
+		return "rename " + a.Name + " to " + a.NewName
+	case StockDividend:
+		return "stock dividend " + a.Name
+	}
+	return "split " + a.Name
+}
+
+// ----------------------------------------------------------------------------
+
+// AdjustQuotes back-adjusts a series of quotes for corporate actions, returning an adjusted
+// copy. Quotes of an action's Name before its ex-date are scaled so that the series is
+// continuous across it: splits and stock dividends scale prices down and volumes up by
+// their ratio, cash dividends scale prices by one less the dividend's share of the
+// preceding quote's midpoint, and spin-offs scale prices by one less their cost fraction.
+// Renames carry the new name back through earlier quotes.
+func AdjustQuotes(quotes []Quote, actions []CorporateAction) []Quote {
+	adjusted := append([]Quote(nil), quotes...)
+	for _, a := range sortedActions(actions) {
+		prev := -1
+		for i := range adjusted {
+			if adjusted[i].Name == a.Name && adjusted[i].Timestamp.Before(a.ExDate) {
+				if prev == -1 || !adjusted[i].Timestamp.Before(adjusted[prev].Timestamp) {
+					prev = i
+				}
+			}
+		}
+		if prev == -1 {
+			continue
+		}
+		mid, _ := adjusted[prev].Mid()
+		price, volume := a.factors(mid)
+
+		for i := range adjusted {
+			q := &adjusted[i]
+			if q.Name != a.Name || !q.Timestamp.Before(a.ExDate) {
+				continue
+			}
+			if a.Type == Rename {
+				q.Name = a.NewName
+				continue
+			}
+			q.Bid = QuotedMetric{scalePrice(q.Bid.Price, price), scaleVolume(q.Bid.Volume, volume)}
+			q.Ask = QuotedMetric{scalePrice(q.Ask.Price, price), scaleVolume(q.Ask.Volume, volume)}
+		}
+	}
+	return adjusted
+}
+
+// AdjustBars back-adjusts a series of bars for corporate actions, as AdjustQuotes does
+// for quotes. Cash dividends are taken as a share of the preceding bar's close.
+func AdjustBars(bars []Bar, actions []CorporateAction) []Bar {
+	adjusted := append([]Bar(nil), bars...)
+	for _, a := range sortedActions(actions) {
+		prev := -1
+		for i := range adjusted {
+			if adjusted[i].Name == a.Name && adjusted[i].Start.Before(a.ExDate) {
+				if prev == -1 || !adjusted[i].Start.Before(adjusted[prev].Start) {
+					prev = i
+				}
+			}
+		}
+		if prev == -1 {
+			continue
+		}
+		price, volume := a.factors(adjusted[prev].Close)
+
+		for i := range adjusted {
+			b := &adjusted[i]
+			if b.Name != a.Name || !b.Start.Before(a.ExDate) {
+				continue
+			}
+			if a.Type == Rename {
+				b.Name = a.NewName
+				continue
+			}
+			b.Open, b.High = scalePrice(b.Open, price), scalePrice(b.High, price)
+			b.Low, b.Close = scalePrice(b.Low, price), scalePrice(b.Close, price)
+			b.Volume = scaleVolume(b.Volume, volume)
+		}
+	}
+	return adjusted
+}
+
+// factors returns the factors by which a corporate action scales earlier prices and
+// volumes, given the last price before its ex-date.
+func (a CorporateAction) factors(last Price) (price, volume float64) {
+	switch a.Type {
+	case Split, StockDividend:
+		return float64(a.Old) / float64(a.New), float64(a.New) / float64(a.Old)
+	case CashDividend:
+		if last > 0 {
+			return 1 - float64(a.Amount)/float64(last), 1
+		}
+	case SpinOff:
+		return 1 - a.CostFraction, 1
+	}
+	return 1, 1
+}
+
+// sortedActions returns corporate actions in ex-date order.
+func sortedActions(actions []CorporateAction) []CorporateAction {
+	sorted := append([]CorporateAction(nil), actions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ExDate.Before(sorted[j].ExDate)
+	})
+	return sorted
+}
+
+func scalePrice(p Price, f float64) Price {
+	return Price(math.Round(float64(p) * f))
+}
+
+func scaleVolume(v Volume, f float64) Volume {
+	return Volume(math.Round(float64(v) * f))
+}
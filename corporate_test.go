@@ -0,0 +1,245 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func mockActionHolding() *Holding {
+	h, _ := Buy(mockPortfolioTx("AAPL", true, 50.00, 100, 0))
+	h.Add(mockPortfolioTx("AAPL", true, 60.00, 50, 0))
+	return h
+}
+
+func lotVolumes(h *Holding) (vols []Volume, prices []Price, costs []Amount) {
+	for _, lot := range h.Lots {
+		vols = append(vols, lot.Volume)
+		prices = append(prices, lot.Price)
+		costs = append(costs, lot.Cost)
+	}
+	return vols, prices, costs
+}
+
+func TestHolding_Split(t *testing.T) {
+	tests := []struct {
+		name       string
+		newShares  int
+		oldShares  int
+		wantVols   []Volume
+		wantPrices []Price
+		wantCosts  []Amount
+		want       ActionResult
+	}{
+		{"two for one", 2, 1, []Volume{200, 100}, []Price{2500, 3000}, []Amount{500000, 300000}, ActionResult{}},
+		{"one for three", 1, 3, []Volume{33, 16}, []Price{15000, 18000}, []Amount{495000, 288000},
+			ActionResult{Cash: 15000, Relieved: 17000, Gain: -2000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := mockActionHolding()
+			got := h.Split(tt.newShares, tt.oldShares, NewPrice(150.00))
+			if got != tt.want {
+				t.Errorf("Split() = %+v, want %+v", got, tt.want)
+			}
+			vols, prices, costs := lotVolumes(h)
+			if !reflect.DeepEqual(vols, tt.wantVols) || !reflect.DeepEqual(prices, tt.wantPrices) || !reflect.DeepEqual(costs, tt.wantCosts) {
+				t.Errorf("Split() lots = %v %v %v, want %v %v %v", vols, prices, costs, tt.wantVols, tt.wantPrices, tt.wantCosts)
+			}
+			var cost Amount
+			for _, c := range tt.wantCosts {
+				cost += c
+			}
+			if h.Volume != tt.wantVols[0]+tt.wantVols[1] || h.Cost != cost || h.Realized() != tt.want.Gain {
+				t.Errorf("Split() holding = %+v", h)
+			}
+		})
+	}
+}
+
+func TestHolding_Dividend(t *testing.T) {
+	long := mockActionHolding()
+	short, _ := SellShort(mockShortTx(false, 20.00, 10))
+	if got := long.Dividend(NewPrice(0.50)); got != 7500 || long.Realized() != 7500 || long.Dividends != 7500 {
+		t.Errorf("Dividend() on long holding = %v, realized %v", got, long.Realized())
+	}
+	if got := short.Dividend(NewPrice(0.50)); got != -500 || short.Realized() != -500 {
+		t.Errorf("Dividend() on short holding = %v, realized %v", got, short.Realized())
+	}
+}
+
+func TestPortfolio_ApplyAction(t *testing.T) {
+	exDate := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	p, l := NewPortfolio(1000000), NewLedger()
+	tx := mockPortfolioTx("AAPL", true, 50.00, 101, 0)
+	reliefs, _ := p.Apply(tx)
+	l.RecordTransaction(tx, reliefs)
+
+	actions := []CorporateAction{
+		{Type: CashDividend, Name: "AAPL", ExDate: exDate, Amount: NewPrice(0.25)},
+		{Type: SpinOff, Name: "AAPL", ExDate: exDate, New: 1, Old: 2, NewName: "SPIN", CostFraction: 0.2, Price: NewPrice(10.00)},
+		{Type: Rename, Name: "AAPL", ExDate: exDate, NewName: "APPLE"},
+		{Type: Split, Name: "MSFT", ExDate: exDate, New: 2, Old: 1},
+	}
+	for _, a := range actions {
+		r, err := p.ApplyAction(a)
+		if err != nil {
+			t.Fatalf("ApplyAction(%v) error = %v", a.Type, err)
+		}
+		if err := l.RecordAction(a, r); err != nil {
+			t.Fatalf("RecordAction(%v) error = %v", a.Type, err)
+		}
+	}
+
+	apple, spin := p.Holdings["APPLE"], p.Holdings["SPIN"]
+	if _, ok := p.Holdings["AAPL"]; ok || apple == nil || spin == nil {
+		t.Fatalf("Holdings = %v, want APPLE and SPIN", p.Names())
+	}
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"cash", p.Cash, Amount(1000000 - 505000 + 2525 + 500)},
+		{"apple volume", apple.Volume, Volume(101)},
+		{"apple cost", apple.Cost, Amount(404000)},
+		{"spin volume", spin.Volume, Volume(50)},
+		{"spin cost", spin.Cost, Amount(100000)},
+		{"spin lot date", spin.Lots[0].Date, apple.Lots[0].Date},
+		{"realized", p.Realized(), Amount(2525 - 500)},
+		{"ledger apple", l.Balance(SecuritiesAccount.Sub("APPLE"), exDate), apple.Cost},
+		{"ledger aapl", l.Balance(SecuritiesAccount.Sub("AAPL"), exDate), Amount(0)},
+		{"ledger spin", l.Balance(SecuritiesAccount.Sub("SPIN"), exDate), spin.Cost},
+		{"ledger cash", l.Balance(CashAccount, exDate), p.Cash - 1000000},
+		{"ledger pnl", -l.Balance(PnLAccount, exDate) - l.Balance(DividendsAccount, exDate), p.Realized()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPortfolio_ApplyAction_merge(t *testing.T) {
+	exDate := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	p := NewPortfolio(1000000)
+	p.Apply(mockPortfolioTx("AAPL", true, 50.00, 101, 0))
+	p.Apply(mockPortfolioTx("SPIN", true, 10.00, 20, 0))
+	p.Apply(mockPortfolioTx("SPIN", false, 12.00, 10, 100))
+	p.Apply(mockPortfolioTx("APPLE", true, 40.00, 10, 0))
+	p.Apply(mockPortfolioTx("APPLE", false, 45.00, 5, 0))
+	realized := p.Realized()
+
+	// Fractional shares cashed out of a spin-off realize P&L in the existing holding.
+	spinOff := CorporateAction{Type: SpinOff, Name: "AAPL", ExDate: exDate, New: 1, Old: 2, NewName: "SPIN", CostFraction: 0.2, Price: NewPrice(7.00)}
+	r, err := p.ApplyAction(spinOff)
+	if err != nil {
+		t.Fatalf("ApplyAction() error = %v", err)
+	}
+	if r.Gain == 0 || p.Realized() != realized+r.Gain {
+		t.Errorf("Realized() after spin-off = %v, want %v", p.Realized(), realized+r.Gain)
+	}
+	if spin := p.Holdings["SPIN"]; spin.Volume != 60 || spin.Fees != 100 {
+		t.Errorf("SPIN volume = %v fees = %v, want 60 and 100", spin.Volume, spin.Fees)
+	}
+
+	// Renaming into an existing holding keeps the realized P&L of both.
+	p.ApplyAction(CorporateAction{Type: CashDividend, Name: "AAPL", ExDate: exDate, Amount: NewPrice(0.25)})
+	realized = p.Realized()
+	if _, err := p.ApplyAction(CorporateAction{Type: Rename, Name: "AAPL", ExDate: exDate, NewName: "APPLE"}); err != nil {
+		t.Fatalf("ApplyAction() error = %v", err)
+	}
+	if p.Realized() != realized || p.Holdings["APPLE"].Dividends != NewAmount(NewPrice(0.25), 101) {
+		t.Errorf("Realized() after rename = %v, want %v", p.Realized(), realized)
+	}
+}
+
+func TestPortfolio_ApplyAction_errors(t *testing.T) {
+	p := NewPortfolio(0)
+	p.Apply(mockPortfolioTx("AAPL", true, 50.00, 100, 0))
+	p.Apply(mockPortfolioTx("APPLE", false, 50.00, 100, 0))
+
+	tests := []struct {
+		name string
+		a    CorporateAction
+	}{
+		{"zero ratio", CorporateAction{Type: Split, Name: "AAPL", New: 0, Old: 1}},
+		{"no new name", CorporateAction{Type: Rename, Name: "AAPL"}},
+		{"bad cost fraction", CorporateAction{Type: SpinOff, Name: "AAPL", New: 1, Old: 1, NewName: "SPIN", CostFraction: 2}},
+		{"merge long into short", CorporateAction{Type: Rename, Name: "AAPL", NewName: "APPLE"}},
+		{"spin off long into short", CorporateAction{Type: SpinOff, Name: "AAPL", New: 1, Old: 1, NewName: "APPLE", CostFraction: 0.2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.ApplyAction(tt.a); errors.Cause(err) != ErrInvalidAction {
+				t.Errorf("ApplyAction() error = %v, want %v", err, ErrInvalidAction)
+			}
+			if h := p.Holdings["AAPL"]; h == nil || h.Name != "AAPL" || h.Volume != 100 || h.Cost != 500000 {
+				t.Errorf("ApplyAction() modified holding on failure: %+v", h)
+			}
+		})
+	}
+}
+
+func TestAdjustQuotes(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2017, 6, d, 16, 0, 0, 0, time.UTC) }
+	quote := func(name string, bid, ask Price, d int) Quote {
+		return Quote{Name: name, Bid: QuotedMetric{bid, 100}, Ask: QuotedMetric{ask, 100}, Timestamp: day(d)}
+	}
+	quotes := []Quote{
+		quote("AAPL", 19990, 20010, 1),
+		quote("AAPL", 19990, 20010, 2),
+		quote("MSFT", 5000, 5010, 2),
+		quote("APPLE", 10200, 10220, 4),
+	}
+	actions := []CorporateAction{
+		{Type: Rename, Name: "AAPL", ExDate: day(4), NewName: "APPLE"},
+		{Type: Split, Name: "AAPL", ExDate: day(3), New: 2, Old: 1},
+		{Type: CashDividend, Name: "AAPL", ExDate: day(2), Amount: 2000},
+	}
+	got := AdjustQuotes(quotes, actions)
+	want := []Quote{
+		// A dividend of 10% of the prior mid, then a two for one split.
+		{Name: "APPLE", Bid: QuotedMetric{8996, 200}, Ask: QuotedMetric{9005, 200}, Timestamp: day(1)},
+		quote("APPLE", 9995, 10005, 2),
+		quote("MSFT", 5000, 5010, 2),
+		quote("APPLE", 10200, 10220, 4),
+	}
+	want[1].Bid.Volume, want[1].Ask.Volume = 200, 200
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AdjustQuotes() = %+v, want %+v", got, want)
+	}
+	if quotes[0].Name != "AAPL" || quotes[0].Bid.Price != 19990 {
+		t.Errorf("AdjustQuotes() modified its input")
+	}
+
+	bars := []Bar{{Name: "AAPL", Open: 20000, High: 20100, Low: 19900, Close: 20000, Volume: 500, Start: day(1)}}
+	gotBars := AdjustBars(bars, actions[1:2])
+	wantBars := []Bar{{Name: "AAPL", Open: 10000, High: 10050, Low: 9950, Close: 10000, Volume: 1000, Start: day(1)}}
+	if !reflect.DeepEqual(gotBars, wantBars) {
+		t.Errorf("AdjustBars() = %+v, want %+v", gotBars, wantBars)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrLevelNotFound is returned when an update refers to a price level not in a book.
+	ErrLevelNotFound = errors.New("price level not found")
+	// ErrLevelExists is returned when a price level is added to a book that already has it.
+	ErrLevelExists = errors.New("price level already exists")
+)
+
+// DepthAction refers to the kind of change a DepthUpdate makes to a price level.
+type DepthAction int
+
+const (
+	// AddLevel adds a new price level to a book.
+	AddLevel DepthAction = iota // 0
+	// ModifyLevel changes the volume of a price level.
+	ModifyLevel
+	// DeleteLevel removes a price level from a book.
+	DeleteLevel
+)
+
+// DepthUpdate is an incremental change to one price level of a book.
+type DepthUpdate struct {
+	Action DepthAction
+	Bid    bool
+	QuotedMetric
+	Timestamp time.Time
+}
+
+// ----------------------------------------------------------------------------
+
+// Depth is a depth-of-book for a security: the volume quoted at each price level on either
+// side, best price first. When Levels is non-zero, only that many levels are kept per side,
+// and levels added beyond them are dropped.
+type Depth struct {
+	Name      string
+	Levels    int
+	Bids      []QuotedMetric
+	Asks      []QuotedMetric
+	Timestamp time.Time
+}
+
+// NewDepth returns a new, empty depth-of-book keeping a number of levels per side.
+func NewDepth(name string, levels int) *Depth {
+	return &Depth{Name: name, Levels: levels}
+}
+
+// Apply an incremental update to a book.
+func (d *Depth) Apply(u DepthUpdate) error {
+	if u.Timestamp.Before(d.Timestamp) {
+		return errors.Wrapf(ErrOutOfOrder, "depth update at %s", u.Timestamp)
+	}
+	if u.Price <= 0 {
+		return errors.Wrapf(ErrZeroValue, "depth update price %s", u.Price)
+	}
+	side := d.side(u.Bid)
+	i, found := d.search(u.Bid, u.Price)
+
+	switch {
+	case u.Action == AddLevel && found:
+		return errors.Wrap(ErrLevelExists, u.Price.String())
+	case u.Action != AddLevel && !found:
+		return errors.Wrap(ErrLevelNotFound, u.Price.String())
+	case u.Action == DeleteLevel, u.Action == ModifyLevel && u.Volume == 0:
+		*side = append((*side)[:i], (*side)[i+1:]...)
+	case u.Action == ModifyLevel:
+		(*side)[i].Volume = u.Volume
+	case u.Volume != 0 && (d.Levels == 0 || i < d.Levels):
+		*side = append(*side, QuotedMetric{})
+		copy((*side)[i+1:], (*side)[i:])
+		(*side)[i] = u.QuotedMetric
+		if d.Levels != 0 && len(*side) > d.Levels {
+			*side = (*side)[:d.Levels]
+		}
+	}
+	d.Timestamp = u.Timestamp
+	return nil
+}
+
+// Add a price level to a side of a book.
+func (d *Depth) Add(bid bool, price Price, vol Volume, t time.Time) error {
+	return d.Apply(DepthUpdate{Action: AddLevel, Bid: bid, QuotedMetric: QuotedMetric{price, vol}, Timestamp: t})
+}
+
+// Modify the volume of a price level on a side of a book; a volume of zero deletes it.
+func (d *Depth) Modify(bid bool, price Price, vol Volume, t time.Time) error {
+	return d.Apply(DepthUpdate{Action: ModifyLevel, Bid: bid, QuotedMetric: QuotedMetric{price, vol}, Timestamp: t})
+}
+
+// Delete a price level from a side of a book.
+func (d *Depth) Delete(bid bool, price Price, t time.Time) error {
+	return d.Apply(DepthUpdate{Action: DeleteLevel, Bid: bid, QuotedMetric: QuotedMetric{Price: price}, Timestamp: t})
+}
+
+// Quote returns the top of a book as a quote. An empty side is quoted as a zero metric.
+func (d *Depth) Quote() Quote {
+	q := Quote{Name: d.Name, Timestamp: d.Timestamp}
+	if len(d.Bids) != 0 {
+		q.Bid = d.Bids[0]
+	}
+	if len(d.Asks) != 0 {
+		q.Ask = d.Asks[0]
+	}
+	return q
+}
+
+// WeightedMid returns the midpoint of the volume-weighted average prices of the best
+// levels of each side of a book; all levels are used if levels is zero.
+func (d *Depth) WeightedMid(levels int) (Price, error) {
+	bid, bidVol := depthTotal(d.Bids, levels)
+	ask, askVol := depthTotal(d.Asks, levels)
+	if bidVol == 0 || askVol == 0 {
+		return 0, ErrNilValue
+	}
+	return midpoint(Price(roundDiv(bid, Amount(bidVol))), Price(roundDiv(ask, Amount(askVol)))), nil
+}
+
+// Imbalance returns the difference between the bid and ask volume of the best levels of a
+// book, as a percentage of their total in hundredths of a percent; positive values
+// indicate more volume bid. All levels are used if levels is zero.
+func (d *Depth) Imbalance(levels int) (Amount, error) {
+	_, bidVol := depthTotal(d.Bids, levels)
+	_, askVol := depthTotal(d.Asks, levels)
+	if bidVol+askVol == 0 {
+		return 0, ErrZeroValue
+	}
+	return roundDiv((Amount(bidVol)-Amount(askVol))*10000, Amount(bidVol)+Amount(askVol)), nil
+}
+
+// Available returns the volume a buy or sell could trade against a book at prices no
+// worse than a limit.
+func (d *Depth) Available(buy bool, limit Price) Volume {
+	var vol Volume
+	for _, level := range *d.side(!buy) {
+		if !marketable(buy, limit, level.Price) {
+			break
+		}
+		vol += level.Volume
+	}
+	return vol
+}
+
+// Size returns the volume of an order's remainder that the book could fill at once:
+// up to the order's limit for limit orders, and across every level for market orders.
+func (d *Depth) Size(o *Order) Volume {
+	var vol Volume
+	if o.Logic == Limit {
+		vol = d.Available(o.Buy, o.Price)
+	} else {
+		for _, level := range *d.side(!o.Buy) {
+			vol += level.Volume
+		}
+	}
+	if rem := o.Remaining(); vol > rem {
+		return rem
+	}
+	return vol
+}
+
+// side returns the levels of the bid or ask side of a book.
+func (d *Depth) side(bid bool) *[]QuotedMetric {
+	if bid {
+		return &d.Bids
+	}
+	return &d.Asks
+}
+
+// search returns the index of a price on a side of a book, or where it would be inserted.
+func (d *Depth) search(bid bool, p Price) (int, bool) {
+	levels := *d.side(bid)
+	i := sort.Search(len(levels), func(i int) bool {
+		if bid {
+			return levels[i].Price <= p
+		}
+		return levels[i].Price >= p
+	})
+	return i, i < len(levels) && levels[i].Price == p
+}
+
+// depthTotal returns the notional and volume of the best levels of a side.
+func depthTotal(levels []QuotedMetric, n int) (notional Amount, vol Volume) {
+	if n == 0 || n > len(levels) {
+		n = len(levels)
+	}
+	for _, level := range levels[:n] {
+		notional += NewAmount(level.Price, level.Volume)
+		vol += level.Volume
+	}
+	return notional, vol
+}
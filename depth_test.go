@@ -0,0 +1,140 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func mockDepth() *Depth {
+	t := time.Date(2017, 1, 3, 9, 30, 0, 0, time.UTC)
+	d := NewDepth("AAPL", 3)
+	for _, u := range []DepthUpdate{
+		{AddLevel, true, QuotedMetric{9900, 100}, t},
+		{AddLevel, true, QuotedMetric{9950, 200}, t},
+		{AddLevel, true, QuotedMetric{9800, 300}, t},
+		{AddLevel, false, QuotedMetric{10000, 100}, t},
+		{AddLevel, false, QuotedMetric{10100, 400}, t},
+	} {
+		if err := d.Apply(u); err != nil {
+			panic(err)
+		}
+	}
+	return d
+}
+
+func TestDepth_Apply(t *testing.T) {
+	at := time.Date(2017, 1, 3, 9, 31, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		update   DepthUpdate
+		wantBids []QuotedMetric
+		wantAsks []QuotedMetric
+		wantErr  error
+	}{
+		{"add inside", DepthUpdate{AddLevel, false, QuotedMetric{9990, 50}, at},
+			[]QuotedMetric{{9950, 200}, {9900, 100}, {9800, 300}},
+			[]QuotedMetric{{9990, 50}, {10000, 100}, {10100, 400}}, nil},
+		{"add truncates worst level", DepthUpdate{AddLevel, true, QuotedMetric{9975, 50}, at},
+			[]QuotedMetric{{9975, 50}, {9950, 200}, {9900, 100}},
+			[]QuotedMetric{{10000, 100}, {10100, 400}}, nil},
+		{"add beyond levels is dropped", DepthUpdate{AddLevel, true, QuotedMetric{9700, 50}, at},
+			[]QuotedMetric{{9950, 200}, {9900, 100}, {9800, 300}},
+			[]QuotedMetric{{10000, 100}, {10100, 400}}, nil},
+		{"modify", DepthUpdate{ModifyLevel, false, QuotedMetric{10100, 150}, at},
+			[]QuotedMetric{{9950, 200}, {9900, 100}, {9800, 300}},
+			[]QuotedMetric{{10000, 100}, {10100, 150}}, nil},
+		{"modify to zero deletes", DepthUpdate{ModifyLevel, true, QuotedMetric{9950, 0}, at},
+			[]QuotedMetric{{9900, 100}, {9800, 300}},
+			[]QuotedMetric{{10000, 100}, {10100, 400}}, nil},
+		{"delete", DepthUpdate{DeleteLevel, false, QuotedMetric{Price: 10000}, at},
+			[]QuotedMetric{{9950, 200}, {9900, 100}, {9800, 300}},
+			[]QuotedMetric{{10100, 400}}, nil},
+		{"add existing", DepthUpdate{AddLevel, true, QuotedMetric{9900, 10}, at}, nil, nil, ErrLevelExists},
+		{"delete missing", DepthUpdate{DeleteLevel, true, QuotedMetric{Price: 9000}, at}, nil, nil, ErrLevelNotFound},
+		{"out of order", DepthUpdate{ModifyLevel, true, QuotedMetric{9900, 10}, at.Add(-time.Hour)}, nil, nil, ErrOutOfOrder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := mockDepth()
+			err := d.Apply(tt.update)
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(d.Bids, tt.wantBids) || !reflect.DeepEqual(d.Asks, tt.wantAsks) {
+				t.Errorf("Apply() = %v / %v, want %v / %v", d.Bids, d.Asks, tt.wantBids, tt.wantAsks)
+			}
+		})
+	}
+}
+
+func TestDepth_Quote(t *testing.T) {
+	d := mockDepth()
+	want := Quote{Name: "AAPL", Bid: QuotedMetric{9950, 200}, Ask: QuotedMetric{10000, 100}, Timestamp: d.Timestamp}
+	if got := d.Quote(); got != want {
+		t.Errorf("Quote() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDepth_metrics(t *testing.T) {
+	d := mockDepth()
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		// Bid VWAP over two levels is 99.33, ask VWAP is 100.80.
+		{"weighted mid", first(d.WeightedMid(2)), Price(10007)},
+		{"weighted mid top", first(d.WeightedMid(1)), Price(9975)},
+		// 600 bid against 500 ask.
+		{"imbalance", first(d.Imbalance(0)), Amount(909)},
+		{"available buy", d.Available(true, 10050), Volume(100)},
+		{"available sell", d.Available(false, 9900), Volume(300)},
+		{"size limit", d.Size(NewOrder("AAPL", true, Limit, 10100, 1000, d.Timestamp)), Volume(500)},
+		{"size market", d.Size(NewOrder("AAPL", false, Market, 0, 250, d.Timestamp)), Volume(250)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+
+	empty := NewDepth("AAPL", 0)
+	if _, err := empty.WeightedMid(0); err != ErrNilValue {
+		t.Errorf("WeightedMid() of empty book error = %v, want %v", err, ErrNilValue)
+	}
+	if _, err := empty.Imbalance(0); err != ErrZeroValue {
+		t.Errorf("Imbalance() of empty book error = %v, want %v", err, ErrZeroValue)
+	}
+}
+
+// first returns the first of a value and an error.
+func first(v interface{}, _ error) interface{} {
+	return v
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"math"
+)
+
+// FeeSchedule calculates the fee due on a transaction.
+// Fees are expressed as an Amount; a negative fee is a rebate.
+type FeeSchedule interface {
+	Fee(tx *Transaction) Amount
+}
+
+// PerShareFee charges a flat rate in dollars for every unit transacted.
+type PerShareFee struct {
+	Rate float64
+}
+
+// Fee returns Rate multiplied by the transaction's volume.
+func (f PerShareFee) Fee(tx *Transaction) Amount {
+	return Amount(math.Round(f.Rate * 100 * float64(tx.Volume)))
+}
+
+// PercentageFee charges a fraction of a transaction's notional amount.
+type PercentageFee struct {
+	Rate float64
+}
+
+// Fee returns Rate multiplied by the transaction's notional amount.
+func (f PercentageFee) Fee(tx *Transaction) Amount {
+	return Amount(math.Round(f.Rate * float64(NewAmount(tx.Price, tx.Volume))))
+}
+
+// CappedFee bounds the fee of an underlying schedule between a minimum and maximum.
+// A zero Max leaves the fee uncapped.
+type CappedFee struct {
+	Schedule FeeSchedule
+	Min, Max Amount
+}
+
+// Fee returns the underlying fee, clamped to [Min, Max].
+func (f CappedFee) Fee(tx *Transaction) Amount {
+	fee := f.Schedule.Fee(tx)
+	if fee < f.Min {
+		fee = f.Min
+	}
+	if f.Max != 0 && fee > f.Max {
+		fee = f.Max
+	}
+	return fee
+}
+
+// MakerTakerFee charges per-share rates in dollars depending on whether a transaction
+// added or removed liquidity. Exchange rebates are given as a negative rate.
+type MakerTakerFee struct {
+	Maker, Taker float64
+}
+
+// Fee returns the maker or taker rate multiplied by the transaction's volume.
+func (f MakerTakerFee) Fee(tx *Transaction) Amount {
+	if tx.Liquidity == Maker {
+		return PerShareFee{f.Maker}.Fee(tx)
+	}
+	return PerShareFee{f.Taker}.Fee(tx)
+}
+
+// CompositeFee sums the fees of several schedules, such as a broker commission
+// charged alongside an exchange fee.
+type CompositeFee []FeeSchedule
+
+// Fee returns the total fee of all schedules.
+func (f CompositeFee) Fee(tx *Transaction) (fee Amount) {
+	for _, s := range f {
+		fee += s.Fee(tx)
+	}
+	return fee
+}
+
+// ----------------------------------------------------------------------------
+
+// FeeTier applies a schedule until cumulative traded volume reaches UpTo.
+// A zero UpTo marks the final, unbounded tier.
+type FeeTier struct {
+	UpTo     Volume
+	Schedule FeeSchedule
+}
+
+// TieredFee charges volume-tiered fees based on the cumulative volume it has priced.
+// Transactions that straddle a tier boundary are charged at each tier's rate for the
+// volume falling within it.
+type TieredFee struct {
+	Tiers  []FeeTier
+	traded Volume
+}
+
+// NewTieredFee returns a new tiered fee schedule, ordered by ascending tier bounds.
+func NewTieredFee(tiers ...FeeTier) *TieredFee {
+	return &TieredFee{Tiers: tiers}
+}
+
+// Fee returns the fee of a transaction and adds its volume to the traded total.
+func (f *TieredFee) Fee(tx *Transaction) (fee Amount) {
+	remaining := tx.Volume
+	for _, tier := range f.Tiers {
+		if remaining == 0 {
+			break
+		}
+		if tier.UpTo != 0 && f.traded >= tier.UpTo {
+			continue
+		}
+		vol := remaining
+		if tier.UpTo != 0 && f.traded+vol > tier.UpTo {
+			vol = tier.UpTo - f.traded
+		}
+		part := *tx
+		part.Volume = vol
+		fee += tier.Schedule.Fee(&part)
+
+		f.traded += vol
+		remaining -= vol
+	}
+	f.traded += remaining
+	return fee
+}
+
+// Traded returns the cumulative volume priced by a tiered schedule.
+func (f *TieredFee) Traded() Volume {
+	return f.traded
+}
+
+// Reset clears the cumulative traded volume, such as at the start of a billing period.
+func (f *TieredFee) Reset() {
+	f.traded = 0
+}
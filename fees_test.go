@@ -0,0 +1,101 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"testing"
+	"time"
+)
+
+func mockFeeTx(vol Volume, liquidity Liquidity) *Transaction {
+	return &Transaction{
+		Name:         "AAPL",
+		Buy:          true,
+		QuotedMetric: QuotedMetric{NewPrice(50.00), vol},
+		Timestamp:    time.Time{},
+		Liquidity:    liquidity,
+	}
+}
+
+func TestFeeSchedule_Fee(t *testing.T) {
+	tests := []struct {
+		name string
+		s    FeeSchedule
+		tx   *Transaction
+		want Amount
+	}{
+		{"per share", PerShareFee{Rate: 0.005}, mockFeeTx(1000, Taker), 500},
+		{"percentage", PercentageFee{Rate: 0.001}, mockFeeTx(100, Taker), 500},
+		{"min capped", CappedFee{PerShareFee{0.005}, 100, 0}, mockFeeTx(10, Taker), 100},
+		{"max capped", CappedFee{PerShareFee{0.005}, 100, 300}, mockFeeTx(1000, Taker), 300},
+		{"taker", MakerTakerFee{Maker: -0.002, Taker: 0.003}, mockFeeTx(1000, Taker), 300},
+		{"maker rebate", MakerTakerFee{Maker: -0.002, Taker: 0.003}, mockFeeTx(1000, Maker), -200},
+		{"composite", CompositeFee{PerShareFee{0.005}, PercentageFee{0.001}}, mockFeeTx(100, Taker), 550},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.Fee(tt.tx); got != tt.want {
+				t.Errorf("FeeSchedule.Fee() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTieredFee_Fee(t *testing.T) {
+	f := NewTieredFee(
+		FeeTier{UpTo: 1000, Schedule: PerShareFee{0.01}},
+		FeeTier{UpTo: 0, Schedule: PerShareFee{0.005}},
+	)
+	tests := []struct {
+		name       string
+		tx         *Transaction
+		want       Amount
+		wantTraded Volume
+	}{
+		{"first tier", mockFeeTx(600, Taker), 600, 600},
+		{"straddles tiers", mockFeeTx(600, Taker), 400 + 100, 1200},
+		{"second tier", mockFeeTx(200, Taker), 100, 1400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Fee(tt.tx); got != tt.want {
+				t.Errorf("TieredFee.Fee() = %v, want %v", got, tt.want)
+			}
+			if got := f.Traded(); got != tt.wantTraded {
+				t.Errorf("TieredFee.Traded() = %v, want %v", got, tt.wantTraded)
+			}
+		})
+	}
+	f.Reset()
+	if got := f.Fee(mockFeeTx(100, Taker)); got != 100 {
+		t.Errorf("TieredFee.Fee() after Reset = %v, want %v", got, 100)
+	}
+}
+
+func TestTransaction_ApplyFee(t *testing.T) {
+	tx := mockFeeTx(1000, Taker)
+	tx.ApplyFee(PerShareFee{0.005})
+	tx.ApplyFee(MakerTakerFee{Taker: 0.003})
+
+	if want := Amount(800); tx.Fee != want {
+		t.Errorf("Transaction.Fee = %v, want %v", tx.Fee, want)
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidIdentifier is returned when an identifier has the wrong length or characters.
+	ErrInvalidIdentifier = errors.New("invalid identifier")
+	// ErrCheckDigit is the cause of every CheckDigitError.
+	ErrCheckDigit = errors.New("invalid check digit")
+	// ErrNoConversion is returned when an identifier has no equivalent of another kind.
+	ErrNoConversion = errors.New("identifier cannot be converted")
+)
+
+// CheckDigitError is returned when an identifier's check digit does not match its body.
+type CheckDigitError struct {
+	ID   string
+	Want byte
+	Got  byte
+}
+
+func (e *CheckDigitError) Error() string {
+	return fmt.Sprintf("%s: %s: want %c, got %c", ErrCheckDigit, e.ID, e.Want, e.Got)
+}
+
+// Cause returns ErrCheckDigit, so that errors.Cause identifies every check digit error.
+func (e *CheckDigitError) Cause() error {
+	return ErrCheckDigit
+}
+
+// Identifier is a validated security identifier, usable as the Name of a holding.
+type Identifier interface {
+	fmt.Stringer
+	Validate() error
+}
+
+// ParseIdentifier parses an ISIN, CUSIP or SEDOL, told apart by their length.
+func ParseIdentifier(s string) (Identifier, error) {
+	var (
+		id  Identifier
+		err error
+	)
+	switch s = normalizeID(s); len(s) {
+	case 12:
+		id, err = ParseISIN(s)
+	case 9:
+		id, err = ParseCUSIP(s)
+	case 7:
+		id, err = ParseSEDOL(s)
+	default:
+		err = errors.Wrap(ErrInvalidIdentifier, s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// ISIN is an International Securities Identification Number: a two letter country code,
+// a nine character national identifier and a check digit.
+type ISIN string
+
+// ParseISIN parses and validates an ISIN.
+func ParseISIN(s string) (ISIN, error) {
+	id := ISIN(normalizeID(s))
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (id ISIN) String() string {
+	return string(id)
+}
+
+// Validate checks an ISIN's format and check digit.
+func (id ISIN) Validate() error {
+	s := string(id)
+	if len(s) != 12 || !isLetters(s[:2]) || !isAlphanumeric(s[2:11]) || !isDigits(s[11:]) {
+		return errors.Wrapf(ErrInvalidIdentifier, "ISIN %q", s)
+	}
+	return checkDigit(s, isinCheckDigit(s[:11]))
+}
+
+// Country returns the country code of an ISIN, or an empty string if the ISIN is invalid.
+func (id ISIN) Country() string {
+	if id.Validate() != nil {
+		return ""
+	}
+	return string(id[:2])
+}
+
+// CUSIP returns the CUSIP embedded in a United States or Canadian ISIN.
+func (id ISIN) CUSIP() (CUSIP, error) {
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	if c := id.Country(); c != "US" && c != "CA" {
+		return "", errors.Wrapf(ErrNoConversion, "ISIN %s to CUSIP", id)
+	}
+	return ParseCUSIP(string(id[2:11]))
+}
+
+// SEDOL returns the SEDOL embedded in a British or Irish ISIN.
+func (id ISIN) SEDOL() (SEDOL, error) {
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	if c := id.Country(); (c != "GB" && c != "IE") || id[2:4] != "00" {
+		return "", errors.Wrapf(ErrNoConversion, "ISIN %s to SEDOL", id)
+	}
+	return ParseSEDOL(string(id[4:11]))
+}
+
+// newISIN returns the ISIN of a country code and nine character national identifier.
+func newISIN(country, nsin string) (ISIN, error) {
+	body := country + nsin
+	return ParseISIN(body + string(isinCheckDigit(body)))
+}
+
+// isinCheckDigit computes the Luhn check digit of an ISIN body, with letters expanded
+// to the two digit numbers 10 through 35.
+func isinCheckDigit(body string) byte {
+	var digits []byte
+	for i := 0; i < len(body); i++ {
+		digits = append(digits, []byte(fmt.Sprint(charValue(body[i])))...)
+	}
+	var sum int
+	for i := range digits {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// ----------------------------------------------------------------------------
+
+// CUSIP is a nine character identifier of a North American security: a six character
+// issuer code, a two character issue code and a check digit.
+type CUSIP string
+
+// ParseCUSIP parses and validates a CUSIP.
+func ParseCUSIP(s string) (CUSIP, error) {
+	id := CUSIP(normalizeID(s))
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (id CUSIP) String() string {
+	return string(id)
+}
+
+// Validate checks a CUSIP's format and check digit.
+func (id CUSIP) Validate() error {
+	s := string(id)
+	if len(s) != 9 || !isDigits(s[8:]) || strings.Trim(s[:8], alphanumeric+"*@#") != "" {
+		return errors.Wrapf(ErrInvalidIdentifier, "CUSIP %q", s)
+	}
+	return checkDigit(s, cusipCheckDigit(s[:8]))
+}
+
+// ISIN returns the ISIN of a CUSIP under a country code, either "US" or "CA".
+func (id CUSIP) ISIN(country string) (ISIN, error) {
+	if country != "US" && country != "CA" {
+		return "", errors.Wrapf(ErrNoConversion, "CUSIP %s to %s ISIN", id, country)
+	}
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return newISIN(country, string(id))
+}
+
+// cusipCheckDigit computes the check digit of a CUSIP body, doubling every second character.
+func cusipCheckDigit(body string) byte {
+	var sum int
+	for i := 0; i < len(body); i++ {
+		v := charValue(body[i])
+		if i%2 == 1 {
+			v *= 2
+		}
+		sum += v/10 + v%10
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// ----------------------------------------------------------------------------
+
+// SEDOL is a seven character Stock Exchange Daily Official List identifier of a security
+// listed in the United Kingdom or Ireland, ending in a check digit. It contains no vowels.
+type SEDOL string
+
+// ParseSEDOL parses and validates a SEDOL.
+func ParseSEDOL(s string) (SEDOL, error) {
+	id := SEDOL(normalizeID(s))
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (id SEDOL) String() string {
+	return string(id)
+}
+
+// Validate checks a SEDOL's format and check digit.
+func (id SEDOL) Validate() error {
+	s := string(id)
+	if len(s) != 7 || !isAlphanumeric(s[:6]) || strings.ContainsAny(s, "AEIOU") || !isDigits(s[6:]) {
+		return errors.Wrapf(ErrInvalidIdentifier, "SEDOL %q", s)
+	}
+	return checkDigit(s, sedolCheckDigit(s[:6]))
+}
+
+// ISIN returns the ISIN of a SEDOL under a country code, either "GB" or "IE".
+func (id SEDOL) ISIN(country string) (ISIN, error) {
+	if country != "GB" && country != "IE" {
+		return "", errors.Wrapf(ErrNoConversion, "SEDOL %s to %s ISIN", id, country)
+	}
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return newISIN(country, "00"+string(id))
+}
+
+// sedolCheckDigit computes the weighted check digit of a SEDOL body.
+func sedolCheckDigit(body string) byte {
+	weights := [...]int{1, 3, 1, 7, 3, 9}
+	var sum int
+	for i := 0; i < len(body); i++ {
+		sum += charValue(body[i]) * weights[i]
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// ----------------------------------------------------------------------------
+
+const alphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// normalizeID trims and upper-cases an identifier.
+func normalizeID(s string) string {
+	return strings.ToUpper(strings.TrimSpace(s))
+}
+
+// charValue returns the value of an identifier character: digits are themselves, letters
+// run from 10 for A to 35 for Z, and the CUSIP characters *, @ and # are 36 through 38.
+func charValue(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 10
+	}
+	return 36 + strings.IndexByte("*@#", c)
+}
+
+// checkDigit compares the last character of an identifier to its expected check digit.
+func checkDigit(id string, want byte) error {
+	if got := id[len(id)-1]; got != want {
+		return &CheckDigitError{ID: id, Want: want, Got: got}
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	return strings.Trim(s, alphanumeric[:10]) == ""
+}
+
+func isLetters(s string) bool {
+	return strings.Trim(s, alphanumeric[10:]) == ""
+}
+
+func isAlphanumeric(s string) bool {
+	return strings.Trim(s, alphanumeric) == ""
+}
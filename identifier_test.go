@@ -0,0 +1,136 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseIdentifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Identifier
+		wantErr error
+	}{
+		{"isin", "US0378331005", ISIN("US0378331005"), nil},
+		{"isin lower case", " gb0002634946 ", ISIN("GB0002634946"), nil},
+		{"cusip", "037833100", CUSIP("037833100"), nil},
+		{"cusip with letter", "38259P508", CUSIP("38259P508"), nil},
+		{"sedol", "0263494", SEDOL("0263494"), nil},
+		{"sedol with letters", "BH4HKS3", SEDOL("BH4HKS3"), nil},
+		{"isin check digit", "US0378331006", nil, ErrCheckDigit},
+		{"cusip check digit", "037833101", nil, ErrCheckDigit},
+		{"sedol check digit", "0263495", nil, ErrCheckDigit},
+		{"isin country", "120378331005", nil, ErrInvalidIdentifier},
+		{"sedol vowel", "BH4HKA3", nil, ErrInvalidIdentifier},
+		{"wrong length", "AAPL", nil, ErrInvalidIdentifier},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseIdentifier(tt.s)
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("ParseIdentifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseIdentifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDigitError(t *testing.T) {
+	_, err := ParseISIN("US0378331006")
+	if errors.Cause(err) != ErrCheckDigit {
+		t.Fatalf("errors.Cause() = %v, want %v", errors.Cause(err), ErrCheckDigit)
+	}
+	if cde, ok := err.(*CheckDigitError); !ok || cde.Want != '5' || cde.Got != '6' {
+		t.Errorf("ParseISIN() error = %#v, want a CheckDigitError expecting 5", err)
+	}
+	if _, err := CUSIP("037833101").ISIN("US"); err == nil {
+		t.Fatalf("CUSIP.ISIN() of bad check digit: expected error")
+	} else if cde, ok := err.(*CheckDigitError); !ok || cde.Want != '0' || cde.Got != '1' {
+		t.Errorf("CUSIP.ISIN() error = %#v, want a CheckDigitError expecting 0", err)
+	}
+}
+
+func TestIdentifier_conversion(t *testing.T) {
+	isin := func(s string) ISIN { id, _ := ParseISIN(s); return id }
+	tests := []struct {
+		name    string
+		convert func() (Identifier, error)
+		want    Identifier
+		wantErr error
+	}{
+		{"isin to cusip", func() (Identifier, error) { return isin("US38259P5089").CUSIP() }, CUSIP("38259P508"), nil},
+		{"cusip to isin", func() (Identifier, error) { return CUSIP("037833100").ISIN("US") }, ISIN("US0378331005"), nil},
+		{"isin to sedol", func() (Identifier, error) { return isin("GB0002634946").SEDOL() }, SEDOL("0263494"), nil},
+		{"sedol to isin", func() (Identifier, error) { return SEDOL("BH4HKS3").ISIN("GB") }, ISIN("GB00BH4HKS39"), nil},
+		{"irish sedol to isin", func() (Identifier, error) { return SEDOL("B4BNMY3").ISIN("IE") }, ISIN("IE00B4BNMY34"), nil},
+		{"gb isin to cusip", func() (Identifier, error) { return isin("GB0002634946").CUSIP() }, CUSIP(""), ErrNoConversion},
+		{"cusip to gb isin", func() (Identifier, error) { return CUSIP("037833100").ISIN("GB") }, ISIN(""), ErrNoConversion},
+		{"bad cusip to isin", func() (Identifier, error) { return CUSIP("037833101").ISIN("US") }, ISIN(""), ErrCheckDigit},
+		{"bad sedol to isin", func() (Identifier, error) { return SEDOL("BH4HKS4").ISIN("GB") }, ISIN(""), ErrCheckDigit},
+		{"empty isin to cusip", func() (Identifier, error) { return ISIN("").CUSIP() }, CUSIP(""), ErrInvalidIdentifier},
+		{"short isin to sedol", func() (Identifier, error) { return ISIN("US123").SEDOL() }, SEDOL(""), ErrInvalidIdentifier},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.convert()
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestISIN_Country(t *testing.T) {
+	for id, want := range map[ISIN]string{"US0378331005": "US", "": "", "U": "", "US123": ""} {
+		if got := id.Country(); got != want {
+			t.Errorf("ISIN(%q).Country() = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	r := NewRegistry()
+	r.Register(mockInstrument())
+	if err := r.Register(Instrument{Symbol: "BAD", ISIN: "US0378331006"}); errors.Cause(err) != ErrCheckDigit {
+		t.Errorf("Register() of bad ISIN error = %v, want %v", err, ErrCheckDigit)
+	}
+
+	// Holdings and orders may be keyed by identifier rather than symbol.
+	for _, name := range []string{"AAPL", "US0378331005", "037833100"} {
+		inst, err := r.Resolve(name)
+		if err != nil || inst.Symbol != "AAPL" {
+			t.Errorf("Resolve(%s) = %v, %v", name, inst, err)
+		}
+		if err := r.ValidateOrder(NewOrder(name, true, Market, 0, 100, time.Time{})); err != nil {
+			t.Errorf("ValidateOrder() of %s error = %v", name, err)
+		}
+	}
+}
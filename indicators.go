@@ -0,0 +1,459 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"math"
+)
+
+// Indicators in this file are streaming: each is updated one observation at a time in
+// constant time, and reports whether it has seen enough observations to be Ready.
+// Values are computed in floating point and rounded to the nearest cent on output.
+// Oscillators bounded between 0 and 100 are returned as an Amount in hundredths of a
+// percent, to be formatted with ToPercent.
+
+// SMA is a simple moving average over the last N prices.
+type SMA struct {
+	N int
+
+	window []float64
+	next   int
+	sum    float64
+	count  int
+}
+
+// NewSMA returns a new simple moving average over n prices.
+func NewSMA(n int) *SMA {
+	return &SMA{N: n, window: make([]float64, n)}
+}
+
+// Update adds a price to a moving average and returns its value.
+func (s *SMA) Update(p Price) Price {
+	s.add(float64(p))
+	return s.Value()
+}
+
+func (s *SMA) add(x float64) {
+	if s.count == s.N {
+		s.sum -= s.window[s.next]
+	} else {
+		s.count++
+	}
+	s.window[s.next] = x
+	s.sum += x
+	s.next = (s.next + 1) % s.N
+}
+
+func (s *SMA) value() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / float64(s.count)
+}
+
+// Value returns the average of the prices in a moving average's window.
+func (s *SMA) Value() Price {
+	return roundPrice(s.value())
+}
+
+// Ready reports whether a moving average's window is full.
+func (s *SMA) Ready() bool {
+	return s.count == s.N
+}
+
+// ----------------------------------------------------------------------------
+
+// EMA is an exponential moving average with a smoothing factor of 2/(N+1),
+// seeded with the simple average of its first N prices.
+type EMA struct {
+	N int
+
+	seed  SMA
+	value float64
+	ready bool
+}
+
+// NewEMA returns a new exponential moving average over n prices.
+func NewEMA(n int) *EMA {
+	return &EMA{N: n, seed: *NewSMA(n)}
+}
+
+// Update adds a price to an exponential moving average and returns its value.
+func (e *EMA) Update(p Price) Price {
+	e.add(float64(p))
+	return e.Value()
+}
+
+func (e *EMA) add(x float64) {
+	if e.ready {
+		e.value += 2 / float64(e.N+1) * (x - e.value)
+		return
+	}
+	e.seed.add(x)
+	e.value = e.seed.value()
+	e.ready = e.seed.Ready()
+}
+
+// Value returns the current value of an exponential moving average.
+func (e *EMA) Value() Price {
+	return roundPrice(e.value)
+}
+
+// Ready reports whether an exponential moving average has been seeded.
+func (e *EMA) Ready() bool {
+	return e.ready
+}
+
+// ----------------------------------------------------------------------------
+
+// WMA is a linearly weighted moving average over the last N prices,
+// weighing the latest price N and the oldest 1.
+type WMA struct {
+	N int
+
+	window      []float64
+	next, count int
+	sum, total  float64
+}
+
+// NewWMA returns a new weighted moving average over n prices.
+func NewWMA(n int) *WMA {
+	return &WMA{N: n, window: make([]float64, n)}
+}
+
+// Update adds a price to a weighted moving average and returns its value.
+func (w *WMA) Update(p Price) Price {
+	x := float64(p)
+	if w.count == w.N {
+		w.total += float64(w.N)*x - w.sum
+		w.sum += x - w.window[w.next]
+	} else {
+		w.count++
+		w.total += float64(w.count) * x
+		w.sum += x
+	}
+	w.window[w.next] = x
+	w.next = (w.next + 1) % w.N
+	return w.Value()
+}
+
+// Value returns the current value of a weighted moving average.
+func (w *WMA) Value() Price {
+	if w.count == 0 {
+		return 0
+	}
+	return roundPrice(w.total / float64(w.count*(w.count+1)/2))
+}
+
+// Ready reports whether a weighted moving average's window is full.
+func (w *WMA) Ready() bool {
+	return w.count == w.N
+}
+
+// ----------------------------------------------------------------------------
+
+// RSI is the relative strength index of a price series over N periods, using Wilder's smoothing.
+type RSI struct {
+	N int
+
+	prev          float64
+	gain, loss    float64
+	changes       int
+	seeded, ready bool
+}
+
+// NewRSI returns a new relative strength index over n periods.
+func NewRSI(n int) *RSI {
+	return &RSI{N: n}
+}
+
+// Update adds a price to a relative strength index and returns its value.
+func (r *RSI) Update(p Price) Amount {
+	x := float64(p)
+	if !r.seeded {
+		r.prev, r.seeded = x, true
+		return r.Value()
+	}
+	change := x - r.prev
+	r.prev = x
+	gain, loss := math.Max(change, 0), math.Max(-change, 0)
+
+	if r.changes < r.N {
+		r.changes++
+		r.gain += gain / float64(r.N)
+		r.loss += loss / float64(r.N)
+		r.ready = r.changes == r.N
+	} else {
+		r.gain = (r.gain*float64(r.N-1) + gain) / float64(r.N)
+		r.loss = (r.loss*float64(r.N-1) + loss) / float64(r.N)
+	}
+	return r.Value()
+}
+
+// Value returns the current relative strength index, in hundredths of a percent.
+func (r *RSI) Value() Amount {
+	if !r.ready {
+		return 0
+	}
+	if r.loss == 0 {
+		return 10000
+	}
+	return Amount(math.Round(10000 - 10000/(1+r.gain/r.loss)))
+}
+
+// Ready reports whether a relative strength index has seen N price changes.
+func (r *RSI) Ready() bool {
+	return r.ready
+}
+
+// ----------------------------------------------------------------------------
+
+// MACDValue is the output of a MACD indicator.
+type MACDValue struct {
+	MACD, Signal, Histogram Price
+}
+
+// MACD is the moving average convergence divergence of a price series: the difference
+// between a fast and slow EMA, along with an EMA of that difference as its signal line.
+type MACD struct {
+	fast, slow, signal EMA
+}
+
+// NewMACD returns a new MACD indicator, commonly with periods of 12, 26 and 9.
+func NewMACD(fast, slow, signal int) *MACD {
+	return &MACD{fast: *NewEMA(fast), slow: *NewEMA(slow), signal: *NewEMA(signal)}
+}
+
+// Update adds a price to a MACD indicator and returns its value.
+func (m *MACD) Update(p Price) MACDValue {
+	m.fast.add(float64(p))
+	m.slow.add(float64(p))
+	if m.slow.Ready() {
+		m.signal.add(m.fast.value - m.slow.value)
+	}
+	return m.Value()
+}
+
+// Value returns the current value of a MACD indicator.
+func (m *MACD) Value() MACDValue {
+	if !m.slow.Ready() {
+		return MACDValue{}
+	}
+	macd := m.fast.value - m.slow.value
+	return MACDValue{
+		MACD:      roundPrice(macd),
+		Signal:    roundPrice(m.signal.value),
+		Histogram: roundPrice(macd - m.signal.value),
+	}
+}
+
+// Ready reports whether a MACD indicator's signal line has been seeded.
+func (m *MACD) Ready() bool {
+	return m.signal.Ready()
+}
+
+// ----------------------------------------------------------------------------
+
+// Band is the output of a Bollinger Bands indicator.
+type Band struct {
+	Upper, Middle, Lower Price
+}
+
+// Bollinger is a set of Bollinger Bands: a simple moving average over N prices,
+// bounded above and below by K population standard deviations.
+type Bollinger struct {
+	K float64
+
+	sma   SMA
+	sumSq float64
+}
+
+// NewBollinger returns new Bollinger Bands over n prices, commonly 20 prices and 2 deviations.
+func NewBollinger(n int, k float64) *Bollinger {
+	return &Bollinger{K: k, sma: *NewSMA(n)}
+}
+
+// Update adds a price to Bollinger Bands and returns their value.
+func (b *Bollinger) Update(p Price) Band {
+	x := float64(p)
+	if b.sma.Ready() {
+		old := b.sma.window[b.sma.next]
+		b.sumSq -= old * old
+	}
+	b.sma.add(x)
+	b.sumSq += x * x
+	return b.Value()
+}
+
+// Value returns the current value of Bollinger Bands.
+func (b *Bollinger) Value() Band {
+	n := float64(b.sma.count)
+	if n == 0 {
+		return Band{}
+	}
+	mean := b.sma.value()
+	width := b.K * math.Sqrt(math.Max(0, b.sumSq/n-mean*mean))
+	return Band{Upper: roundPrice(mean + width), Middle: roundPrice(mean), Lower: roundPrice(mean - width)}
+}
+
+// Ready reports whether the window of Bollinger Bands is full.
+func (b *Bollinger) Ready() bool {
+	return b.sma.Ready()
+}
+
+// ----------------------------------------------------------------------------
+
+// ATR is the average true range of a series of bars over N periods, using Wilder's smoothing.
+type ATR struct {
+	N int
+
+	prevClose float64
+	value     float64
+	count     int
+}
+
+// NewATR returns a new average true range over n bars.
+func NewATR(n int) *ATR {
+	return &ATR{N: n}
+}
+
+// Update adds a bar to an average true range and returns its value.
+func (a *ATR) Update(b Bar) Price {
+	high, low := float64(b.High), float64(b.Low)
+	tr := high - low
+	if a.count > 0 {
+		tr = math.Max(tr, math.Max(math.Abs(high-a.prevClose), math.Abs(low-a.prevClose)))
+	}
+	a.prevClose = float64(b.Close)
+
+	if a.count < a.N {
+		a.count++
+		a.value += (tr - a.value) / float64(a.count)
+	} else {
+		a.value = (a.value*float64(a.N-1) + tr) / float64(a.N)
+	}
+	return a.Value()
+}
+
+// Value returns the current average true range.
+func (a *ATR) Value() Price {
+	return roundPrice(a.value)
+}
+
+// Ready reports whether an average true range has seen N bars.
+func (a *ATR) Ready() bool {
+	return a.count >= a.N
+}
+
+// ----------------------------------------------------------------------------
+
+// StochasticValue is the output of a stochastic oscillator, in hundredths of a percent.
+type StochasticValue struct {
+	K, D Amount
+}
+
+// Stochastic is a stochastic oscillator: %K locates a bar's close within the range of
+// the last KPeriod bars, and %D is a simple moving average of %K over DPeriod bars.
+type Stochastic struct {
+	KPeriod int
+
+	highs, lows deque
+	seq         int
+	k           float64
+	d           SMA
+}
+
+// NewStochastic returns a new stochastic oscillator, commonly over 14 and 3 bars.
+func NewStochastic(k, d int) *Stochastic {
+	return &Stochastic{KPeriod: k, d: *NewSMA(d)}
+}
+
+// Update adds a bar to a stochastic oscillator and returns its value.
+func (s *Stochastic) Update(b Bar) StochasticValue {
+	high := windowObs{seq: s.seq, price: b.High}
+	low := windowObs{seq: s.seq, price: b.Low}
+	s.highs.push(high, func(back windowObs) bool { return back.price <= b.High })
+	s.lows.push(low, func(back windowObs) bool { return back.price >= b.Low })
+	if s.seq >= s.KPeriod {
+		s.highs.evict(s.seq - s.KPeriod)
+		s.lows.evict(s.seq - s.KPeriod)
+	}
+	s.seq++
+
+	hh, ll := float64(s.highs.front().Price), float64(s.lows.front().Price)
+	s.k = 50
+	if hh > ll {
+		s.k = 100 * (float64(b.Close) - ll) / (hh - ll)
+	}
+	if s.seq >= s.KPeriod {
+		s.d.add(s.k)
+	}
+	return s.Value()
+}
+
+// Value returns the current value of a stochastic oscillator.
+func (s *Stochastic) Value() StochasticValue {
+	return StochasticValue{K: Amount(math.Round(s.k * 100)), D: Amount(math.Round(s.d.value() * 100))}
+}
+
+// Ready reports whether a stochastic oscillator's %D has a full window.
+func (s *Stochastic) Ready() bool {
+	return s.d.Ready()
+}
+
+// ----------------------------------------------------------------------------
+
+// OBV is on-balance volume: a running total of volume, added on bars that close up
+// and subtracted on bars that close down. Its value is a signed volume.
+type OBV struct {
+	prevClose Price
+	value     Amount
+	seeded    bool
+}
+
+// NewOBV returns a new on-balance volume indicator.
+func NewOBV() *OBV {
+	return &OBV{}
+}
+
+// Update adds a bar to on-balance volume and returns its value.
+func (o *OBV) Update(b Bar) Amount {
+	switch {
+	case !o.seeded:
+		o.seeded = true
+	case b.Close > o.prevClose:
+		o.value += Amount(b.Volume)
+	case b.Close < o.prevClose:
+		o.value -= Amount(b.Volume)
+	}
+	o.prevClose = b.Close
+	return o.value
+}
+
+// Value returns the current on-balance volume.
+func (o *OBV) Value() Amount {
+	return o.value
+}
+
+// roundPrice rounds a floating point number of cents to a price.
+func roundPrice(x float64) Price {
+	return Price(math.Round(x))
+}
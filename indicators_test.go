@@ -0,0 +1,167 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"testing"
+)
+
+func mockIndicatorSeries() []Price {
+	return []Price{
+		4411, 4409, 4415, 4434, 4428, 4420, 4432, 4457, 4481, 4457,
+		4460, 4413, 4422, 4465, 4484, 4523, 4496, 4532, 4512, 4480,
+	}
+}
+
+func mockIndicatorBars() []Bar {
+	return []Bar{
+		{High: 4500, Low: 4400, Close: 4450, Volume: 100},
+		{High: 4520, Low: 4430, Close: 4510, Volume: 200},
+		{High: 4530, Low: 4480, Close: 4490, Volume: 150},
+		{High: 4550, Low: 4470, Close: 4540, Volume: 300},
+		{High: 4540, Low: 4500, Close: 4505, Volume: 250},
+		{High: 4560, Low: 4490, Close: 4555, Volume: 100},
+	}
+}
+
+// lastPrices updates an indicator with a series and returns its last n values.
+func lastPrices(series []Price, n int, update func(Price) Price) []Price {
+	var out []Price
+	for _, p := range series {
+		out = append(out, update(p))
+	}
+	return out[len(out)-n:]
+}
+
+func TestMovingAverages(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(Price) Price
+		want   []Price
+	}{
+		{"SMA", NewSMA(5).Update, []Price{4500, 4509, 4509}},
+		{"EMA", NewEMA(5).Update, []Price{4501, 4504, 4496}},
+		{"WMA", NewWMA(5).Update, []Price{4510, 4514, 4504}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastPrices(mockIndicatorSeries(), 3, tt.update); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMovingAverages_warmup(t *testing.T) {
+	sma, ema, wma := NewSMA(3), NewEMA(3), NewWMA(3)
+	for _, p := range []Price{100, 200} {
+		sma.Update(p)
+		ema.Update(p)
+		wma.Update(p)
+	}
+	if sma.Ready() || ema.Ready() || wma.Ready() {
+		t.Errorf("moving averages ready after 2 of 3 prices")
+	}
+	if sma.Value() != 150 || ema.Value() != 150 {
+		t.Errorf("SMA, EMA = %v, %v, want partial averages of 150", sma.Value(), ema.Value())
+	}
+	if got := wma.Value(); got != 167 {
+		t.Errorf("WMA = %v, want %v", got, Price(167))
+	}
+}
+
+func TestRSI(t *testing.T) {
+	rsi := NewRSI(14)
+	var got []Amount
+	for i, p := range mockIndicatorSeries() {
+		got = append(got, rsi.Update(p))
+		if rsi.Ready() != (i >= 14) {
+			t.Fatalf("Ready() after %d prices = %v", i+1, rsi.Ready())
+		}
+	}
+	if want := []Amount{6761, 6297, 5632}; !reflect.DeepEqual(got[len(got)-3:], want) {
+		t.Errorf("RSI = %v, want %v", got[len(got)-3:], want)
+	}
+
+	rising := NewRSI(3)
+	for _, p := range []Price{100, 110, 120, 130} {
+		rising.Update(p)
+	}
+	if got := rising.Value(); got != 10000 {
+		t.Errorf("RSI of rising series = %v, want %v", got.ToPercent(), Amount(10000).ToPercent())
+	}
+}
+
+func TestMACD(t *testing.T) {
+	macd := NewMACD(3, 6, 4)
+	var got MACDValue
+	for _, p := range mockIndicatorSeries() {
+		got = macd.Update(p)
+	}
+	if want := (MACDValue{MACD: 2, Signal: 9, Histogram: -7}); got != want || !macd.Ready() {
+		t.Errorf("MACD = %+v, want %+v", got, want)
+	}
+}
+
+func TestBollinger(t *testing.T) {
+	b := NewBollinger(5, 2)
+	var got Band
+	for _, p := range mockIndicatorSeries() {
+		got = b.Update(p)
+	}
+	if want := (Band{Upper: 4546, Middle: 4509, Lower: 4471}); got != want {
+		t.Errorf("Bollinger = %+v, want %+v", got, want)
+	}
+
+	flat := NewBollinger(3, 2)
+	for _, p := range []Price{100, 100, 100, 100} {
+		got = flat.Update(p)
+	}
+	if want := (Band{100, 100, 100}); got != want {
+		t.Errorf("Bollinger of flat series = %+v, want %+v", got, want)
+	}
+}
+
+func TestBarIndicators(t *testing.T) {
+	atr, stoch, obv := NewATR(3), NewStochastic(3, 2), NewOBV()
+	for _, b := range mockIndicatorBars() {
+		atr.Update(b)
+		stoch.Update(b)
+		obv.Update(b)
+	}
+	tests := []struct {
+		name      string
+		got, want interface{}
+		ready     bool
+	}{
+		{"ATR", atr.Value(), Price(68), atr.Ready()},
+		{"Stochastic", stoch.Value(), StochasticValue{K: 9444, D: 6910}, stoch.Ready()},
+		{"OBV", obv.Value(), Amount(200), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.ready || !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s = %v (ready %v), want %v", tt.name, tt.got, tt.ready, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnknownInstrument is returned when an instrument is not found in a registry.
+	ErrUnknownInstrument = errors.New("unknown instrument")
+	// ErrDuplicateInstrument is returned when an instrument's symbol or identifier is already registered.
+	ErrDuplicateInstrument = errors.New("instrument already registered")
+	// ErrOffTick is returned when a price does not lie on an instrument's tick grid.
+	ErrOffTick = errors.New("price not a multiple of tick size")
+	// ErrOddLot is returned when a volume is not a whole number of an instrument's lots.
+	ErrOddLot = errors.New("volume not a multiple of lot size")
+)
+
+// AssetClass refers to the kind of security an instrument is.
+type AssetClass int
+
+const (
+	// Equity is a share of stock.
+	Equity AssetClass = iota // 0
+	// Fund is a share of an exchange-traded or mutual fund.
+	Fund
+	// Bond is a fixed income security.
+	Bond
+	// Option is an options contract.
+	Option
+	// Future is a futures contract.
+	Future
+	// Currency is a currency pair.
+	Currency
+)
+
+var assetClassNames = [...]string{"equity", "fund", "bond", "option", "future", "currency"}
+
+func (c AssetClass) String() string {
+	if c < 0 || int(c) >= len(assetClassNames) {
+		return "unknown"
+	}
+	return assetClassNames[c]
+}
+
+// ----------------------------------------------------------------------------
+
+// Instrument is the reference data of a tradeable security. Its Symbol is the Name used
+// for it on quotes, orders, transactions and holdings.
+//
+// Prices must be a multiple of TickSize and volumes a multiple of LotSize; a zero TickSize
+// or LotSize places no constraint. Multiplier is the number of units of the underlying
+// per contract, such as 100 for equity options; zero is taken as one.
+type Instrument struct {
+	Symbol     string
+	ISIN       ISIN
+	CUSIP      CUSIP
+	SEDOL      SEDOL
+	FIGI       string
+	AssetClass AssetClass
+	Currency   string
+	TickSize   Price
+	LotSize    Volume
+	Multiplier int
+	Exchange   string
+}
+
+// ValidatePrice checks that a price lies on an instrument's tick grid.
+func (i *Instrument) ValidatePrice(p Price) error {
+	if i.TickSize > 0 && p%i.TickSize != 0 {
+		return errors.Wrapf(ErrOffTick, "%s price %s, tick %s", i.Symbol, p, i.TickSize)
+	}
+	return nil
+}
+
+// ValidateVolume checks that a volume is a whole number of an instrument's lots.
+func (i *Instrument) ValidateVolume(v Volume) error {
+	if i.LotSize > 0 && v%i.LotSize != 0 {
+		return errors.Wrapf(ErrOddLot, "%s volume %d, lot %d", i.Symbol, v, i.LotSize)
+	}
+	return nil
+}
+
+// Is reports whether a name refers to an instrument, by symbol or by identifier.
+func (i *Instrument) Is(name string) bool {
+	if name == i.Symbol {
+		return true
+	}
+	name = normalizeID(name)
+	for _, id := range i.identifiers() {
+		if name == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateOrder checks that an order is for an instrument, in whole lots, and for limit
+// orders, at a price on its tick grid.
+func (i *Instrument) ValidateOrder(o *Order) error {
+	if !i.Is(o.Name) {
+		return errors.Wrapf(ErrInvalidOrder, "wanted %s, got %s", i.Symbol, o.Name)
+	}
+	if o.Logic == Limit {
+		if err := i.ValidatePrice(o.Price); err != nil {
+			return err
+		}
+	}
+	return i.ValidateVolume(o.Volume)
+}
+
+// RoundToTick rounds a price to an instrument's tick grid, up or down.
+func (i *Instrument) RoundToTick(p Price, up bool) Price {
+	if i.TickSize <= 0 {
+		return p
+	}
+	rem := p % i.TickSize
+	if rem < 0 {
+		rem += i.TickSize
+	}
+	if rem == 0 {
+		return p
+	}
+	if up {
+		return p - rem + i.TickSize
+	}
+	return p - rem
+}
+
+// Notional returns the value of a volume of an instrument at a price, scaled by its multiplier.
+func (i *Instrument) Notional(p Price, v Volume) Amount {
+	m := Amount(i.Multiplier)
+	if m == 0 {
+		m = 1
+	}
+	return NewAmount(p, v) * m
+}
+
+// ----------------------------------------------------------------------------
+
+// Registry is a collection of instruments, looked up by symbol or by any of their identifiers.
+type Registry struct {
+	symbols map[string]*Instrument
+	ids     map[string]*Instrument
+}
+
+// NewRegistry returns a new, empty instrument registry.
+func NewRegistry() *Registry {
+	return &Registry{symbols: make(map[string]*Instrument), ids: make(map[string]*Instrument)}
+}
+
+// Register an instrument. Its identifiers must be valid, and neither they nor its symbol
+// may already be registered.
+func (r *Registry) Register(inst Instrument) error {
+	if inst.Symbol == "" {
+		return errors.Wrap(ErrNilValue, "instrument symbol")
+	}
+	for _, id := range []Identifier{inst.ISIN, inst.CUSIP, inst.SEDOL} {
+		if id.String() == "" {
+			continue
+		}
+		if err := id.Validate(); err != nil {
+			return errors.Wrap(err, inst.Symbol)
+		}
+	}
+	if _, ok := r.symbols[inst.Symbol]; ok {
+		return errors.Wrap(ErrDuplicateInstrument, inst.Symbol)
+	}
+	ids := inst.identifiers()
+	for _, id := range ids {
+		if _, ok := r.ids[id]; ok {
+			return errors.Wrap(ErrDuplicateInstrument, id)
+		}
+	}
+	r.symbols[inst.Symbol] = &inst
+	for _, id := range ids {
+		r.ids[id] = &inst
+	}
+	return nil
+}
+
+// Lookup returns the instrument with a symbol.
+func (r *Registry) Lookup(symbol string) (*Instrument, error) {
+	inst, ok := r.symbols[symbol]
+	if !ok {
+		return nil, errors.Wrap(ErrUnknownInstrument, symbol)
+	}
+	return inst, nil
+}
+
+// LookupID returns the instrument with an ISIN, CUSIP, SEDOL or FIGI.
+func (r *Registry) LookupID(id string) (*Instrument, error) {
+	inst, ok := r.ids[normalizeID(id)]
+	if !ok {
+		return nil, errors.Wrap(ErrUnknownInstrument, id)
+	}
+	return inst, nil
+}
+
+// Resolve returns the instrument a name refers to, by symbol or by identifier,
+// such as a holding keyed by ISIN.
+func (r *Registry) Resolve(name string) (*Instrument, error) {
+	if inst, ok := r.symbols[name]; ok {
+		return inst, nil
+	}
+	if inst, ok := r.ids[normalizeID(name)]; ok {
+		return inst, nil
+	}
+	return nil, errors.Wrap(ErrUnknownInstrument, name)
+}
+
+// Symbols returns the symbols of every registered instrument, in sorted order.
+func (r *Registry) Symbols() []string {
+	symbols := make([]string, 0, len(r.symbols))
+	for s := range r.symbols {
+		symbols = append(symbols, s)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// ValidateOrder checks an order against the instrument its name refers to.
+func (r *Registry) ValidateOrder(o *Order) error {
+	inst, err := r.Resolve(o.Name)
+	if err != nil {
+		return err
+	}
+	return inst.ValidateOrder(o)
+}
+
+// identifiers returns the non-empty identifiers of an instrument, normalized for lookup.
+func (i *Instrument) identifiers() []string {
+	var ids []string
+	for _, id := range []string{string(i.ISIN), string(i.CUSIP), string(i.SEDOL), i.FIGI} {
+		if id != "" {
+			ids = append(ids, normalizeID(id))
+		}
+	}
+	return ids
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func mockInstrument() Instrument {
+	return Instrument{
+		Symbol:     "AAPL",
+		ISIN:       "US0378331005",
+		CUSIP:      "037833100",
+		FIGI:       "BBG000B9XRY4",
+		AssetClass: Equity,
+		Currency:   "USD",
+		TickSize:   1,
+		LotSize:    100,
+		Exchange:   "XNAS",
+	}
+}
+
+func TestInstrument_ValidateOrder(t *testing.T) {
+	inst := mockInstrument()
+	inst.TickSize = 5
+	at := time.Time{}
+	tests := []struct {
+		name    string
+		o       *Order
+		wantErr error
+	}{
+		{"base case", NewOrder("AAPL", true, Limit, 10005, 200, at), nil},
+		{"off tick", NewOrder("AAPL", true, Limit, 10003, 200, at), ErrOffTick},
+		{"odd lot", NewOrder("AAPL", false, Limit, 10005, 150, at), ErrOddLot},
+		{"market ignores tick", NewOrder("AAPL", true, Market, 10003, 100, at), nil},
+		{"other name", NewOrder("MSFT", true, Limit, 10005, 100, at), ErrInvalidOrder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := inst.ValidateOrder(tt.o); errors.Cause(err) != tt.wantErr {
+				t.Errorf("ValidateOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstrument_RoundToTick(t *testing.T) {
+	inst := Instrument{Symbol: "ES", TickSize: 25, Multiplier: 50}
+	tests := []struct {
+		name string
+		p    Price
+		up   bool
+		want Price
+	}{
+		{"on tick", 250050, true, 250050},
+		{"down", 250060, false, 250050},
+		{"up", 250060, true, 250075},
+		{"negative down", -10, false, -25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inst.RoundToTick(tt.p, tt.up); got != tt.want {
+				t.Errorf("RoundToTick() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := inst.Notional(250000, 2); got != 25000000 {
+		t.Errorf("Notional() = %v, want %v", got, Amount(25000000))
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(mockInstrument()); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	r.Register(Instrument{Symbol: "MSFT", ISIN: "US5949181045", FIGI: "bbg000bpH459"})
+
+	dupSymbol := mockInstrument()
+	dupSymbol.ISIN, dupSymbol.CUSIP, dupSymbol.FIGI = "", "", ""
+	dupID := Instrument{Symbol: "AAPL.O", CUSIP: "037833100"}
+	for _, inst := range []Instrument{dupSymbol, dupID} {
+		if err := r.Register(inst); errors.Cause(err) != ErrDuplicateInstrument {
+			t.Errorf("Register(%s) error = %v, want %v", inst.Symbol, err, ErrDuplicateInstrument)
+		}
+	}
+	if _, err := r.Lookup("AAPL.O"); errors.Cause(err) != ErrUnknownInstrument {
+		t.Errorf("Lookup() of rejected instrument error = %v", err)
+	}
+
+	for _, id := range []string{"US0378331005", "037833100", "BBG000B9XRY4"} {
+		if inst, err := r.LookupID(id); err != nil || inst.Symbol != "AAPL" {
+			t.Errorf("LookupID(%s) = %v, %v", id, inst, err)
+		}
+	}
+	for _, id := range []string{"bbg000bph459", "BBG000BPH459"} {
+		if inst, err := r.LookupID(id); err != nil || inst.Symbol != "MSFT" {
+			t.Errorf("LookupID(%s) = %v, %v", id, inst, err)
+		}
+		if inst, err := r.Resolve(id); err != nil || inst.Symbol != "MSFT" {
+			t.Errorf("Resolve(%s) = %v, %v", id, inst, err)
+		}
+	}
+	if got := r.Symbols(); !reflect.DeepEqual(got, []string{"AAPL", "MSFT"}) {
+		t.Errorf("Symbols() = %v", got)
+	}
+
+	if err := r.ValidateOrder(NewOrder("IBM", true, Market, 0, 100, time.Time{})); errors.Cause(err) != ErrUnknownInstrument {
+		t.Errorf("ValidateOrder() of unknown name error = %v, want %v", err, ErrUnknownInstrument)
+	}
+
+	bt := NewBacktest(&mockStrategy{}, 0, nil)
+	bt.Registry = r
+	if _, err := bt.Market("AAPL", true, 50); errors.Cause(err) != ErrOddLot {
+		t.Errorf("Backtest.Market() of odd lot error = %v, want %v", err, ErrOddLot)
+	}
+}
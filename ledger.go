@@ -0,0 +1,248 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnbalanced is returned when the debits and credits of a journal entry do not sum to zero.
+var ErrUnbalanced = errors.New("journal entry does not balance")
+
+// Account identifies a ledger account.
+// Sub-accounts are separated from their parent by a colon, such as "securities:AAPL".
+type Account string
+
+const (
+	// CashAccount holds a fund's cash balance.
+	CashAccount Account = "cash"
+	// SecuritiesAccount holds the cost basis of long holdings, and the credit of short holdings.
+	SecuritiesAccount Account = "securities"
+	// FeesAccount accumulates fees expensed when closing positions or charged outside of trades.
+	FeesAccount Account = "fees"
+	// PnLAccount accumulates realized gains, before closing fees, as credits.
+	PnLAccount Account = "pnl"
+	// DividendsAccount accumulates dividend income as credits.
+	DividendsAccount Account = "dividends"
+	// EquityAccount records capital contributed to and withdrawn from a fund.
+	EquityAccount Account = "equity"
+)
+
+// Sub returns a sub-account of an account.
+func (a Account) Sub(name string) Account {
+	return a + ":" + Account(name)
+}
+
+// Contains reports whether an account is, or is a sub-account of, another.
+func (a Account) Contains(b Account) bool {
+	return a == b || strings.HasPrefix(string(b), string(a)+":")
+}
+
+// Posting is an amount debited to an account. Credits are negative amounts.
+type Posting struct {
+	Account Account
+	Amount  Amount
+}
+
+// Entry is a journal entry of postings whose debits and credits balance.
+type Entry struct {
+	Timestamp time.Time
+	Memo      string
+	Postings  []Posting
+}
+
+// Balanced reports whether the postings of an entry sum to zero.
+func (e Entry) Balanced() bool {
+	var sum Amount
+	for _, p := range e.Postings {
+		sum += p.Amount
+	}
+	return sum == 0
+}
+
+// ----------------------------------------------------------------------------
+
+// Ledger is an append-only double-entry journal.
+type Ledger struct {
+	entries []Entry
+}
+
+// NewLedger returns a new, empty ledger instance.
+func NewLedger() *Ledger {
+	return &Ledger{}
+}
+
+// Post appends a balanced journal entry to a ledger. Zero postings are dropped.
+func (l *Ledger) Post(e Entry) error {
+	if !e.Balanced() {
+		return errors.Wrap(ErrUnbalanced, e.Memo)
+	}
+	postings := make([]Posting, 0, len(e.Postings))
+	for _, p := range e.Postings {
+		if p.Amount != 0 {
+			postings = append(postings, p)
+		}
+	}
+	e.Postings = postings
+	l.entries = append(l.entries, e)
+	return nil
+}
+
+// RecordTransaction journals a transaction along with the lot reliefs it produced,
+// as returned by Holding.Apply or Portfolio.Apply.
+//
+// Volume relieved from lots closes a position: its cost basis is relieved from the
+// securities account, any fee on it is expensed, and the difference between the basis
+// and the transaction's gross amount is realized to P&L. Any remaining volume opens a
+// position, with fees capitalized into its basis.
+func (l *Ledger) RecordTransaction(tx Transaction, reliefs []LotRelief) error {
+	var (
+		securities = SecuritiesAccount.Sub(tx.Name)
+		closed     Volume
+		basis      Amount
+	)
+	for _, r := range reliefs {
+		closed += r.Volume
+		if tx.Buy {
+			basis += r.Proceeds
+		} else {
+			basis += r.Cost
+		}
+	}
+	if closed > tx.Volume {
+		return errors.Wrap(ErrInvalidTx, "lot reliefs exceed transaction volume")
+	}
+
+	var closeFee Amount
+	if tx.Volume != 0 {
+		closeFee = tx.Fee * Amount(closed) / Amount(tx.Volume)
+	}
+	openFee, gross := tx.Fee-closeFee, NewAmount(tx.Price, closed)
+	opened := NewAmount(tx.Price, tx.Volume-closed)
+
+	var postings []Posting
+	if tx.Buy {
+		postings = []Posting{
+			{securities, basis},
+			{FeesAccount, closeFee},
+			{CashAccount, -gross - closeFee},
+			{PnLAccount, gross - basis},
+			{securities, opened + openFee},
+			{CashAccount, -opened - openFee},
+		}
+	} else {
+		postings = []Posting{
+			{CashAccount, gross - closeFee},
+			{FeesAccount, closeFee},
+			{securities, -basis},
+			{PnLAccount, basis - gross},
+			{CashAccount, opened - openFee},
+			{securities, openFee - opened},
+		}
+	}
+	return l.Post(Entry{Timestamp: tx.Timestamp, Memo: txMemo(tx), Postings: postings})
+}
+
+// RecordFee journals a fee paid in cash outside of a transaction, such as a borrow or custody fee.
+func (l *Ledger) RecordFee(amt Amount, memo string, t time.Time) error {
+	return l.Post(Entry{Timestamp: t, Memo: memo, Postings: []Posting{
+		{FeesAccount, amt},
+		{CashAccount, -amt},
+	}})
+}
+
+// RecordDividend journals a cash dividend received on a holding.
+func (l *Ledger) RecordDividend(name string, amt Amount, t time.Time) error {
+	return l.Post(Entry{Timestamp: t, Memo: "dividend " + name, Postings: []Posting{
+		{CashAccount, amt},
+		{DividendsAccount.Sub(name), -amt},
+	}})
+}
+
+// RecordCash journals a movement of capital into a fund, or out of it if negative.
+func (l *Ledger) RecordCash(amt Amount, memo string, t time.Time) error {
+	return l.Post(Entry{Timestamp: t, Memo: memo, Postings: []Posting{
+		{CashAccount, amt},
+		{EquityAccount, -amt},
+	}})
+}
+
+// txMemo describes a transaction for the memo of its journal entry.
+func txMemo(tx Transaction) string {
+	side := "sell"
+	if tx.Buy {
+		side = "buy"
+	}
+	return side + " " + tx.Volume.String() + " " + tx.Name + " @ " + tx.Price.String()
+}
+
+// ----------------------------------------------------------------------------
+
+// Entries returns the entries of a ledger timestamped at or before a time, in the order posted.
+func (l *Ledger) Entries(asOf time.Time) []Entry {
+	var entries []Entry
+	for _, e := range l.entries {
+		if !e.Timestamp.After(asOf) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Balance returns the debit balance of an account and its sub-accounts as of a time.
+// Accounts with a credit balance return a negative amount.
+func (l *Ledger) Balance(a Account, asOf time.Time) (bal Amount) {
+	for _, e := range l.Entries(asOf) {
+		for _, p := range e.Postings {
+			if a.Contains(p.Account) {
+				bal += p.Amount
+			}
+		}
+	}
+	return bal
+}
+
+// TrialBalance returns the debit balance of every account posted to as of a time.
+// The balances of a ledger always sum to zero.
+func (l *Ledger) TrialBalance(asOf time.Time) map[Account]Amount {
+	balances := make(map[Account]Amount)
+	for _, e := range l.Entries(asOf) {
+		for _, p := range e.Postings {
+			balances[p.Account] += p.Amount
+		}
+	}
+	return balances
+}
+
+// Accounts returns the sorted names of every account posted to as of a time.
+func (l *Ledger) Accounts(asOf time.Time) []Account {
+	balances := l.TrialBalance(asOf)
+	accounts := make([]Account, 0, len(balances))
+	for a := range balances {
+		accounts = append(accounts, a)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i] < accounts[j] })
+	return accounts
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestLedger_Post(t *testing.T) {
+	tests := []struct {
+		name    string
+		e       Entry
+		wantErr error
+	}{
+		{"balanced", Entry{Memo: "ok", Postings: []Posting{{CashAccount, 100}, {EquityAccount, -100}}}, nil},
+		{"unbalanced", Entry{Memo: "bad", Postings: []Posting{{CashAccount, 100}, {EquityAccount, -90}}}, ErrUnbalanced},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewLedger().Post(tt.e); errors.Cause(err) != tt.wantErr {
+				t.Errorf("Ledger.Post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLedger_RecordTransaction(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2017, 1, d, 0, 0, 0, 0, time.UTC) }
+	txs := []Transaction{
+		{Name: "AAPL", Buy: true, QuotedMetric: QuotedMetric{NewPrice(100.00), 50}, Timestamp: day(2), Fee: 100},
+		{Name: "AAPL", Buy: false, QuotedMetric: QuotedMetric{NewPrice(110.00), 20}, Timestamp: day(3), Fee: 50},
+		{Name: "GOOGL", Buy: false, QuotedMetric: QuotedMetric{NewPrice(50.00), 10}, Timestamp: day(3), Fee: 20},
+		{Name: "GOOGL", Buy: true, QuotedMetric: QuotedMetric{NewPrice(45.00), 30}, Timestamp: day(4), Fee: 60},
+	}
+
+	l, p := NewLedger(), NewPortfolio(0)
+	if err := l.RecordCash(NewAmount(NewPrice(10000.00), 1), "subscription", day(1)); err != nil {
+		t.Fatalf("Ledger.RecordCash() error = %v", err)
+	}
+	p.Deposit(NewAmount(NewPrice(10000.00), 1))
+	for _, tx := range txs {
+		reliefs, err := p.Apply(tx)
+		if err != nil {
+			t.Fatalf("Portfolio.Apply() error = %v", err)
+		}
+		if err = l.RecordTransaction(tx, reliefs); err != nil {
+			t.Fatalf("Ledger.RecordTransaction() error = %v", err)
+		}
+	}
+	end := day(5)
+
+	tests := []struct {
+		name    string
+		account Account
+		want    Amount
+	}{
+		{"cash", CashAccount, p.Cash},
+		{"long basis", SecuritiesAccount.Sub("AAPL"), p.Holdings["AAPL"].Cost},
+		{"flipped basis", SecuritiesAccount.Sub("GOOGL"), p.Holdings["GOOGL"].Cost},
+		{"net realized", PnLAccount, -p.Realized() - l.Balance(FeesAccount, end)},
+		{"equity", EquityAccount, -NewAmount(NewPrice(10000.00), 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.Balance(tt.account, end); got != tt.want {
+				t.Errorf("Ledger.Balance(%v) = %v, want %v", tt.account, got, tt.want)
+			}
+		})
+	}
+
+	var total Amount
+	for _, bal := range l.TrialBalance(end) {
+		total += bal
+	}
+	if total != 0 {
+		t.Errorf("Ledger.TrialBalance() sums to %v, want 0", total)
+	}
+}
+
+func TestLedger_asOf(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2017, 1, d, 0, 0, 0, 0, time.UTC) }
+	l := NewLedger()
+	l.RecordCash(10000, "subscription", day(1))
+	l.RecordDividend("AAPL", 500, day(3))
+	l.RecordFee(200, "custody", day(5))
+
+	tests := []struct {
+		name string
+		asOf time.Time
+		want map[Account]Amount
+	}{
+		{"before", day(0), map[Account]Amount{}},
+		{"after cash", day(2), map[Account]Amount{CashAccount: 10000, EquityAccount: -10000}},
+		{"after dividend", day(4), map[Account]Amount{CashAccount: 10500, EquityAccount: -10000, DividendsAccount.Sub("AAPL"): -500}},
+		{"after fee", day(5), map[Account]Amount{CashAccount: 10300, EquityAccount: -10000, DividendsAccount.Sub("AAPL"): -500, FeesAccount: 200}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.TrialBalance(tt.asOf); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Ledger.TrialBalance() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got, want := l.Accounts(day(5)), []Account{CashAccount, DividendsAccount.Sub("AAPL"), EquityAccount, FeesAccount}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Ledger.Accounts() = %v, want %v", got, want)
+	}
+	if got := l.Balance(DividendsAccount, day(5)); got != -500 {
+		t.Errorf("Ledger.Balance(dividends) = %v, want -500", got)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrLotNotFound is returned when a lot identifier does not refer to an open lot of a holding.
+var ErrLotNotFound = errors.New("lot not found")
+
+// Lot is a quantity of a security acquired by a single transaction.
+// Cost is the basis of the lot's remaining volume, including fees paid to acquire it.
+type Lot struct {
+	ID     int
+	Volume Volume
+	Price  Price
+	Cost   Amount
+	Date   time.Time
+}
+
+// LotRelief records the disposal of volume from a lot.
+// Proceeds are net of the lot's share of the selling transaction's fee.
+// For a lot sold short, Proceeds are the credit relieved from the lot, Cost is its share
+// of the covering transaction including fees, and Acquired is the date the lot was opened.
+type LotRelief struct {
+	Lot      int
+	Volume   Volume
+	Cost     Amount
+	Proceeds Amount
+	Acquired time.Time
+	Sold     time.Time
+}
+
+// Gain returns the realized gain, or loss if negative, of a lot relief.
+func (r LotRelief) Gain() Amount {
+	return r.Proceeds - r.Cost
+}
+
+// LotMethod refers to the order in which a holding's lots are relieved.
+type LotMethod int
+
+const (
+	// FIFO relieves the earliest acquired lots first.
+	FIFO LotMethod = iota // 0
+	// LIFO relieves the latest acquired lots first.
+	LIFO
+	// HIFO relieves the lots with the highest cost per unit first.
+	HIFO
+	// SpecificID relieves lots chosen by the seller through SellOffLots.
+	SpecificID
+)
+
+// ----------------------------------------------------------------------------
+
+// entry returns the metric of the side that opens a holding's lots.
+func (h *Holding) entry() *TxMetric {
+	if h.Short {
+		return &h.Sell
+	}
+	return &h.Buy
+}
+
+// exit returns the metric of the side that closes a holding's lots.
+func (h *Holding) exit() *TxMetric {
+	if h.Short {
+		return &h.Buy
+	}
+	return &h.Sell
+}
+
+// open adds a transaction's volume to a holding as a new lot on the holding's side.
+func (h *Holding) open(tx Transaction) {
+	entry := h.entry()
+	if h.Volume == 0 {
+		entry.Date = tx.Timestamp
+		h.exited = 0
+	}
+	volume := h.Volume + tx.Volume
+	entry.Price = Price(roundDiv(NewAmount(entry.Price, h.Volume)+NewAmount(tx.Price, tx.Volume), Amount(volume)))
+
+	h.Cost += h.openLot(tx)
+	h.Fees += tx.Fee
+	h.Volume = volume
+}
+
+// close relieves a transaction's volume from a holding's lots by its Method.
+// Volume beyond the holding's flips it through zero, opening a position on the other side.
+func (h *Holding) close(tx Transaction) []LotRelief {
+	if tx.Volume <= h.Volume {
+		return h.relieve(tx, h.lotOrder())
+	}
+	closing, opening := tx, tx
+	closing.Volume = h.Volume
+	closing.Fee = tx.Fee * Amount(h.Volume) / Amount(tx.Volume)
+	opening.Volume -= closing.Volume
+	opening.Fee -= closing.Fee
+
+	var reliefs []LotRelief
+	if closing.Volume != 0 {
+		reliefs = h.relieve(closing, h.lotOrder())
+	}
+	h.Short = !h.Short
+	h.open(opening)
+	return reliefs
+}
+
+// openLot appends a new lot to a holding from transaction data, returning its basis.
+// A bought lot's basis is its cost including fees; a lot sold short is credited its proceeds net of fees.
+func (h *Holding) openLot(tx Transaction) Amount {
+	basis := NewAmount(tx.Price, tx.Volume) + tx.Fee
+	if !tx.Buy {
+		basis = NewAmount(tx.Price, tx.Volume) - tx.Fee
+	}
+	h.invested += basis
+
+	h.lotSeq++
+	h.Lots = append(h.Lots, Lot{
+		ID:     h.lotSeq,
+		Volume: tx.Volume,
+		Price:  tx.Price,
+		Cost:   basis,
+		Date:   tx.Timestamp,
+	})
+	return basis
+}
+
+// ensureLots gives a holding built without lots a single lot spanning its volume.
+func (h *Holding) ensureLots() {
+	if len(h.Lots) != 0 || h.Volume == 0 {
+		return
+	}
+	entry := h.entry()
+	if h.Cost == 0 {
+		h.Cost = NewAmount(entry.Price, h.Volume)
+	}
+	if h.invested == 0 {
+		h.invested = h.Cost
+	}
+	h.lotSeq++
+	h.Lots = []Lot{{ID: h.lotSeq, Volume: h.Volume, Price: entry.Price, Cost: h.Cost, Date: entry.Date}}
+}
+
+// lotIndex returns the index of the lot with the given ID, or -1 if it is not open.
+func (h *Holding) lotIndex(id int) int {
+	for i := range h.Lots {
+		if h.Lots[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// lotOrder returns the indices of a holding's lots in the order its Method relieves them.
+func (h *Holding) lotOrder() []int {
+	order := make([]int, len(h.Lots))
+	for i := range order {
+		order[i] = i
+	}
+	lots := h.Lots
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := lots[order[i]], lots[order[j]]
+		switch h.Method {
+		case LIFO:
+			return a.Date.After(b.Date) || (a.Date.Equal(b.Date) && a.ID > b.ID)
+		case HIFO:
+			return a.Cost*Amount(b.Volume) > b.Cost*Amount(a.Volume)
+		default:
+			return a.Date.Before(b.Date) || (a.Date.Equal(b.Date) && a.ID < b.ID)
+		}
+	})
+	return order
+}
+
+// relieve removes a closing transaction's volume from the lots at the given indices, in order.
+// The caller must ensure the lots hold enough volume to cover the transaction.
+//
+// For a long holding, each lot's cost is relieved against its share of the sale's proceeds
+// net of fees. For a short holding, each lot's credit is relieved against its share of the
+// cost of buying it back, including fees.
+func (h *Holding) relieve(tx Transaction, order []int) []LotRelief {
+	var (
+		reliefs   []LotRelief
+		remaining = tx.Volume
+		net       = NewAmount(tx.Price, tx.Volume) - tx.Fee
+		allocated Amount
+	)
+	if h.Short {
+		net = NewAmount(tx.Price, tx.Volume) + tx.Fee
+	}
+	for _, i := range order {
+		if remaining == 0 {
+			break
+		}
+		lot := &h.Lots[i]
+		if lot.Volume == 0 {
+			continue
+		}
+		vol := lot.Volume
+		if vol > remaining {
+			vol = remaining
+		}
+		basis := lot.Cost * Amount(vol) / Amount(lot.Volume)
+		remaining -= vol
+
+		share := net * Amount(vol) / Amount(tx.Volume)
+		if remaining == 0 {
+			share = net - allocated
+		}
+		allocated += share
+
+		lot.Volume -= vol
+		lot.Cost -= basis
+		r := LotRelief{
+			Lot: lot.ID, Volume: vol, Cost: basis, Proceeds: share,
+			Acquired: lot.Date, Sold: tx.Timestamp,
+		}
+		if h.Short {
+			r.Cost, r.Proceeds = share, basis
+		}
+		reliefs = append(reliefs, r)
+		h.Cost -= basis
+		h.realized += r.Gain()
+	}
+
+	open := h.Lots[:0]
+	for _, lot := range h.Lots {
+		if lot.Volume != 0 {
+			open = append(open, lot)
+		}
+	}
+	h.Lots = open
+
+	h.Fees += tx.Fee
+	h.Volume -= tx.Volume
+	h.entry().Price = h.lotPrice()
+
+	exit, exited := h.exit(), h.exited+tx.Volume
+	if exited == 0 {
+		return reliefs
+	}
+	*exit = TxMetric{
+		Price: Price(roundDiv(NewAmount(exit.Price, h.exited)+NewAmount(tx.Price, tx.Volume), Amount(exited))),
+		Date:  tx.Timestamp,
+	}
+	h.exited = exited
+	return reliefs
+}
+
+// lotPrice returns the volume-weighted average price of a holding's open lots.
+func (h *Holding) lotPrice() Price {
+	if h.Volume == 0 {
+		return h.entry().Price
+	}
+	var total Amount
+	for _, lot := range h.Lots {
+		total += NewAmount(lot.Price, lot.Volume)
+	}
+	return Price(roundDiv(total, Amount(h.Volume)))
+}
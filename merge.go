@@ -0,0 +1,209 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"container/heap"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// QuoteIterator is a source of quotes in timestamp order.
+// Next returns io.EOF once the source is exhausted.
+type QuoteIterator interface {
+	Next() (Quote, error)
+}
+
+// SliceIterator iterates over a slice of quotes.
+type SliceIterator struct {
+	quotes []Quote
+	i      int
+}
+
+// NewSliceIterator returns a new iterator over a slice of quotes.
+func NewSliceIterator(quotes []Quote) *SliceIterator {
+	return &SliceIterator{quotes: quotes}
+}
+
+// Next returns the next quote of a slice.
+func (it *SliceIterator) Next() (Quote, error) {
+	if it.i == len(it.quotes) {
+		return Quote{}, io.EOF
+	}
+	it.i++
+	return it.quotes[it.i-1], nil
+}
+
+// ChanIterator iterates over quotes received from a channel until it is closed,
+// such as those read from a file by another goroutine.
+type ChanIterator <-chan Quote
+
+// Next returns the next quote received from a channel.
+func (it ChanIterator) Next() (Quote, error) {
+	q, ok := <-it
+	if !ok {
+		return Quote{}, io.EOF
+	}
+	return q, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Merger merges many quote iterators, each in timestamp order, into a single stream in
+// timestamp order. Quotes with equal timestamps are yielded in the order their sources
+// were given to NewMerger, and in source order within a source.
+//
+// A merger pulls from its sources only as quotes are consumed, holding at most one
+// quote per source, so its memory is bounded by the number of sources and a slow
+// consumer holds back its producers. The source of the quote last returned is refilled
+// on the following call to Next, so a quote is never held back waiting on its source.
+// A merger is itself a QuoteIterator.
+type Merger struct {
+	sources []QuoteIterator
+	heads   mergeHeap
+	started bool
+	refill  int
+	last    time.Time
+	err     error
+}
+
+// NewMerger returns a new merger of quote iterators.
+func NewMerger(sources ...QuoteIterator) *Merger {
+	return &Merger{sources: sources, refill: -1}
+}
+
+// Next returns the earliest quote remaining across a merger's sources.
+func (m *Merger) Next() (Quote, error) {
+	if m.err != nil {
+		return Quote{}, m.err
+	}
+	if !m.started {
+		m.started = true
+		for i := range m.sources {
+			if err := m.pull(i); err != nil {
+				m.err = err
+				return Quote{}, err
+			}
+		}
+	}
+	if m.refill >= 0 {
+		if err := m.pull(m.refill); err != nil {
+			m.err = err
+			return Quote{}, err
+		}
+		m.refill = -1
+	}
+	if len(m.heads) == 0 {
+		return Quote{}, io.EOF
+	}
+	head := heap.Pop(&m.heads).(mergeHead)
+	m.refill, m.last = head.source, head.quote.Timestamp
+	return head.quote, nil
+}
+
+// Drain passes every quote of an iterator to a function in order, such as Summary.UpdateQuote
+// or Backtest.Step, stopping at the first error.
+func Drain(it QuoteIterator, fn func(Quote) error) error {
+	for {
+		q, err := it.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(q); err != nil {
+			return err
+		}
+	}
+}
+
+// Stream sends the merged quotes of a merger to a channel with room for a number of quotes
+// from another goroutine, blocking while the channel is full. The quote channel is closed
+// once the sources are exhausted, the done channel is closed, or a source fails; in the
+// latter case the error is sent on the error channel, which is then closed.
+func (m *Merger) Stream(buffer int, done <-chan struct{}) (<-chan Quote, <-chan error) {
+	quotes, errc := make(chan Quote, buffer), make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(quotes)
+		err := Drain(m, func(q Quote) error {
+			select {
+			case quotes <- q:
+				return nil
+			case <-done:
+				return io.EOF
+			}
+		})
+		if err != nil && err != io.EOF {
+			errc <- err
+		}
+	}()
+	return quotes, errc
+}
+
+// pull pushes the next quote of a source onto the heap, unless the source is exhausted.
+// A refilled source's quote must not precede the quote last returned from it.
+func (m *Merger) pull(source int) error {
+	q, err := m.sources[source].Next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "quote source %d", source)
+	}
+	if source == m.refill && q.Timestamp.Before(m.last) {
+		return errors.Wrapf(ErrOutOfOrder, "quote source %d at %s", source, q.Timestamp)
+	}
+	heap.Push(&m.heads, mergeHead{quote: q, source: source})
+	return nil
+}
+
+// mergeHead is the next quote of a merger's source.
+type mergeHead struct {
+	quote  Quote
+	source int
+}
+
+// mergeHeap is a min-heap of sources' next quotes, by timestamp then source.
+type mergeHeap []mergeHead
+
+func (h mergeHeap) Len() int { return len(h) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	if !h[i].quote.Timestamp.Equal(h[j].quote.Timestamp) {
+		return h[i].quote.Timestamp.Before(h[j].quote.Timestamp)
+	}
+	return h[i].source < h[j].source
+}
+
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeHead)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
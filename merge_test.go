@@ -0,0 +1,163 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var errMockSource = errors.New("mock source failure")
+
+// failingIterator yields its quotes, then fails.
+type failingIterator struct {
+	SliceIterator
+}
+
+func (it *failingIterator) Next() (Quote, error) {
+	q, err := it.SliceIterator.Next()
+	if err == io.EOF {
+		return Quote{}, errMockSource
+	}
+	return q, err
+}
+
+func mockMergeSources() []QuoteIterator {
+	at := func(sec int) time.Time { return time.Date(2017, 1, 3, 9, 30, sec, 0, time.UTC) }
+	quote := func(name string, sec int) Quote {
+		return Quote{Name: name, Bid: QuotedMetric{9900, 100}, Ask: QuotedMetric{10000, 100}, Timestamp: at(sec)}
+	}
+	return []QuoteIterator{
+		NewSliceIterator([]Quote{quote("AAPL", 1), quote("AAPL", 3), quote("AAPL", 3), quote("AAPL", 7)}),
+		NewSliceIterator(nil),
+		NewSliceIterator([]Quote{quote("MSFT", 0), quote("MSFT", 3), quote("MSFT", 9)}),
+		NewSliceIterator([]Quote{quote("IBM", 3)}),
+	}
+}
+
+func mergedNames(t *testing.T, it QuoteIterator) []string {
+	var names []string
+	err := Drain(it, func(q Quote) error {
+		names = append(names, q.Name+q.Timestamp.Format(":05"))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	return names
+}
+
+func TestMerger_Next(t *testing.T) {
+	got := mergedNames(t, NewMerger(mockMergeSources()...))
+	want := []string{"MSFT:00", "AAPL:01", "AAPL:03", "AAPL:03", "MSFT:03", "IBM:03", "AAPL:07", "MSFT:09"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged = %v, want %v", got, want)
+	}
+}
+
+func TestMerger_live(t *testing.T) {
+	// A quote already received is returned before its source sends another.
+	ch := make(chan Quote, 1)
+	m := NewMerger(ChanIterator(ch))
+	for _, sec := range []int{0, 1} {
+		ch <- Quote{Name: "AAPL", Timestamp: time.Date(2017, 1, 3, 9, 30, sec, 0, time.UTC)}
+		got := make(chan Quote, 1)
+		go func() {
+			q, _ := m.Next()
+			got <- q
+		}()
+		select {
+		case q := <-got:
+			if q.Timestamp.Second() != sec {
+				t.Errorf("Merger.Next() = %v, want second %d", q.Timestamp, sec)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Merger.Next() blocked on its source's next quote")
+		}
+	}
+	close(ch)
+	if _, err := m.Next(); err != io.EOF {
+		t.Errorf("Merger.Next() error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestMerger_errors(t *testing.T) {
+	at := time.Date(2017, 1, 3, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		source  QuoteIterator
+		wantErr error
+	}{
+		{"out of order", NewSliceIterator([]Quote{{Timestamp: at.Add(time.Second)}, {Timestamp: at}}), ErrOutOfOrder},
+		{"failing source", &failingIterator{*NewSliceIterator([]Quote{{Timestamp: at}})}, errMockSource},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMerger(tt.source)
+			err := Drain(m, func(Quote) error { return nil })
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("Drain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, err := m.Next(); errors.Cause(err) != tt.wantErr {
+				t.Errorf("Next() after failure error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMerger_Stream(t *testing.T) {
+	// Merge channel-fed sources, as read by separate goroutines.
+	var sources []QuoteIterator
+	for _, src := range mockMergeSources() {
+		ch := make(chan Quote)
+		go func(src QuoteIterator) {
+			defer close(ch)
+			Drain(src, func(q Quote) error { ch <- q; return nil })
+		}(src)
+		sources = append(sources, ChanIterator(ch))
+	}
+	quotes, errc := NewMerger(sources...).Stream(1, nil)
+
+	s := NewSummary(Holding{})
+	var n int
+	for q := range quotes {
+		s.UpdateQuote(&q)
+		n++
+	}
+	if err := <-errc; err != nil || n != 8 || s.N != 8 {
+		t.Errorf("Stream() delivered %d quotes, summarized %d, error %v", n, s.N, err)
+	}
+
+	// Closing done stops the stream early.
+	done := make(chan struct{})
+	quotes, errc = NewMerger(mockMergeSources()...).Stream(0, done)
+	<-quotes
+	close(done)
+	for range quotes {
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Stream() stopped early with error %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NBBO is the national best bid and offer of a security across venues. The bid and ask
+// volume of its Quote is aggregated across every venue quoting the best price, and
+// BidVenues and AskVenues name those venues in order.
+type NBBO struct {
+	Quote
+	BidVenues []string
+	AskVenues []string
+}
+
+// Consolidator tracks the latest quote of a security from each of several venues and
+// consolidates them into an NBBO. OnUpdate, if set, is called each time the NBBO changes.
+type Consolidator struct {
+	Name     string
+	OnUpdate func(NBBO)
+
+	venues map[string]Quote
+	nbbo   NBBO
+}
+
+// NewConsolidator returns a new consolidator of a security's quotes.
+func NewConsolidator(name string, onUpdate func(NBBO)) *Consolidator {
+	return &Consolidator{
+		Name:     name,
+		OnUpdate: onUpdate,
+		venues:   make(map[string]Quote),
+		nbbo:     NBBO{Quote: Quote{Name: name}},
+	}
+}
+
+// Update a venue's quote, returning the resulting NBBO and whether it changed.
+// A change in timestamp alone is not a change in the NBBO.
+func (c *Consolidator) Update(venue string, q Quote) (NBBO, bool, error) {
+	if q.Name != c.Name {
+		return c.NBBO(), false, errors.Wrapf(ErrInvalidQuote, "wanted %s, got %s", c.Name, q.Name)
+	}
+	if prev, ok := c.venues[venue]; ok && q.Timestamp.Before(prev.Timestamp) {
+		return c.NBBO(), false, errors.Wrapf(ErrOutOfOrder, "%s quote at %s", venue, q.Timestamp)
+	}
+	c.venues[venue] = q
+	nbbo, changed := c.consolidate(q.Timestamp)
+	return nbbo, changed, nil
+}
+
+// Remove a venue, such as one that has stopped quoting, at a time.
+// It returns the resulting NBBO and whether it changed.
+func (c *Consolidator) Remove(venue string, t time.Time) (NBBO, bool) {
+	if _, ok := c.venues[venue]; !ok {
+		return c.NBBO(), false
+	}
+	delete(c.venues, venue)
+	return c.consolidate(t)
+}
+
+// NBBO returns the current NBBO of a consolidator.
+func (c *Consolidator) NBBO() NBBO {
+	nbbo := c.nbbo
+	nbbo.BidVenues = append([]string(nil), c.nbbo.BidVenues...)
+	nbbo.AskVenues = append([]string(nil), c.nbbo.AskVenues...)
+	return nbbo
+}
+
+// Venue returns the latest quote of a venue.
+func (c *Consolidator) Venue(venue string) (Quote, bool) {
+	q, ok := c.venues[venue]
+	return q, ok
+}
+
+// consolidate recomputes the NBBO as of a time, notifying OnUpdate if it changed.
+func (c *Consolidator) consolidate(t time.Time) (NBBO, bool) {
+	venues := make([]string, 0, len(c.venues))
+	for v := range c.venues {
+		venues = append(venues, v)
+	}
+	sort.Strings(venues)
+
+	next := NBBO{Quote: Quote{Name: c.Name}}
+	for _, v := range venues {
+		q := c.venues[v]
+		if p := q.Bid.Price; p > 0 && q.Bid.Volume > 0 {
+			switch {
+			case p > next.Bid.Price:
+				next.Bid, next.BidVenues = q.Bid, []string{v}
+			case p == next.Bid.Price:
+				next.Bid.Volume += q.Bid.Volume
+				next.BidVenues = append(next.BidVenues, v)
+			}
+		}
+		if p := q.Ask.Price; p > 0 && q.Ask.Volume > 0 {
+			switch {
+			case next.Ask.Price == 0 || p < next.Ask.Price:
+				next.Ask, next.AskVenues = q.Ask, []string{v}
+			case p == next.Ask.Price:
+				next.Ask.Volume += q.Ask.Volume
+				next.AskVenues = append(next.AskVenues, v)
+			}
+		}
+	}
+
+	prev := c.nbbo
+	next.Timestamp = prev.Timestamp
+	changed := !reflect.DeepEqual(prev, next)
+	if changed {
+		next.Timestamp = t
+		c.nbbo = next
+		if c.OnUpdate != nil {
+			c.OnUpdate(c.NBBO())
+		}
+	}
+	return c.NBBO(), changed
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func mockVenueQuote(bid Price, bidVol Volume, ask Price, askVol Volume, sec int) Quote {
+	return Quote{
+		Name:      "AAPL",
+		Bid:       QuotedMetric{bid, bidVol},
+		Ask:       QuotedMetric{ask, askVol},
+		Timestamp: time.Date(2017, 1, 3, 9, 30, sec, 0, time.UTC),
+	}
+}
+
+func TestConsolidator_Update(t *testing.T) {
+	var updates []NBBO
+	c := NewConsolidator("AAPL", func(n NBBO) { updates = append(updates, n) })
+
+	tests := []struct {
+		name        string
+		venue       string
+		q           Quote
+		want        NBBO
+		wantChanged bool
+	}{
+		{"first venue", "NYSE", mockVenueQuote(9900, 100, 10000, 200, 0),
+			NBBO{mockVenueQuote(9900, 100, 10000, 200, 0), []string{"NYSE"}, []string{"NYSE"}}, true},
+		{"better bid", "ARCA", mockVenueQuote(9950, 300, 10050, 100, 1),
+			NBBO{mockVenueQuote(9950, 300, 10000, 200, 1), []string{"ARCA"}, []string{"NYSE"}}, true},
+		{"joins best prices", "BATS", mockVenueQuote(9950, 50, 10000, 25, 2),
+			NBBO{mockVenueQuote(9950, 350, 10000, 225, 2), []string{"ARCA", "BATS"}, []string{"BATS", "NYSE"}}, true},
+		{"behind the best", "IEX", mockVenueQuote(9800, 500, 10200, 500, 3),
+			NBBO{mockVenueQuote(9950, 350, 10000, 225, 2), []string{"ARCA", "BATS"}, []string{"BATS", "NYSE"}}, false},
+		{"best ask leaves", "NYSE", mockVenueQuote(9900, 100, 10100, 200, 4),
+			NBBO{mockVenueQuote(9950, 350, 10000, 25, 4), []string{"ARCA", "BATS"}, []string{"BATS"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := c.Update(tt.venue, tt.q)
+			if err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			if changed != tt.wantChanged || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Update() = %+v, %v, want %+v, %v", got, changed, tt.want, tt.wantChanged)
+			}
+		})
+	}
+	if len(updates) != 4 {
+		t.Errorf("OnUpdate called %d times, want 4", len(updates))
+	}
+}
+
+func TestConsolidator_Remove(t *testing.T) {
+	c := NewConsolidator("AAPL", nil)
+	c.Update("NYSE", mockVenueQuote(9900, 100, 10000, 200, 0))
+	c.Update("ARCA", mockVenueQuote(9950, 300, 10050, 100, 1))
+
+	got, changed := c.Remove("ARCA", time.Date(2017, 1, 3, 9, 31, 0, 0, time.UTC))
+	if !changed || got.Bid != (QuotedMetric{9900, 100}) || !reflect.DeepEqual(got.BidVenues, []string{"NYSE"}) {
+		t.Errorf("Remove() = %+v, %v", got, changed)
+	}
+	if _, changed := c.Remove("ARCA", got.Timestamp); changed {
+		t.Errorf("Remove() of unknown venue changed NBBO")
+	}
+	got, _ = c.Remove("NYSE", got.Timestamp)
+	if got.Bid.Price != 0 || got.Ask.Price != 0 || got.BidVenues != nil {
+		t.Errorf("Remove() of last venue = %+v, want empty NBBO", got)
+	}
+}
+
+func TestConsolidator_errors(t *testing.T) {
+	c := NewConsolidator("AAPL", nil)
+	c.Update("NYSE", mockVenueQuote(9900, 100, 10000, 200, 5))
+
+	other := mockVenueQuote(9900, 100, 10000, 200, 6)
+	other.Name = "MSFT"
+	if _, _, err := c.Update("NYSE", other); errors.Cause(err) != ErrInvalidQuote {
+		t.Errorf("Update() of another name error = %v, want %v", err, ErrInvalidQuote)
+	}
+	if _, _, err := c.Update("NYSE", mockVenueQuote(9900, 100, 10000, 200, 4)); errors.Cause(err) != ErrOutOfOrder {
+		t.Errorf("Update() of older quote error = %v, want %v", err, ErrOutOfOrder)
+	}
+}
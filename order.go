@@ -25,8 +25,11 @@ import (
 	"time"
 
 	"github.com/jakeschurch/instruments/internal/ordering"
+	"github.com/pkg/errors"
 )
 
+var ErrInvalidOrder = errors.New("invalid order given")
+
 // Order stores logic for transacting a stock.
 type Order struct {
 	Name string
@@ -63,9 +66,14 @@ func (o *Order) timestampTx() time.Time {
 	return o.timestamp.Add(o.ticker.Duration())
 }
 
+// Remaining returns the volume of an order that has yet to be filled.
+func (o *Order) Remaining() Volume {
+	return o.Volume - o.filled
+}
+
 // Transact a fulfillment of an order; yielding a new transaction struct.
 func (o *Order) Transact(price Price, volume Volume) *Transaction {
-	o.filled -= volume
+	o.filled += volume
 	return &Transaction{
 		Name:         o.Name,
 		Buy:          o.Buy,
@@ -82,8 +90,27 @@ type Transaction struct {
 	Buy  bool
 	QuotedMetric
 	Timestamp time.Time
+	Fee       Amount
+	Liquidity Liquidity
+}
+
+// ApplyFee charges a transaction the fee due under a schedule, returning the fee charged.
+func (tx *Transaction) ApplyFee(s FeeSchedule) Amount {
+	fee := s.Fee(tx)
+	tx.Fee += fee
+	return fee
 }
 
+// Liquidity refers to whether a transaction added or removed liquidity from the market.
+type Liquidity int
+
+const (
+	// Taker indicates that a transaction removed liquidity by trading against a resting quote.
+	Taker Liquidity = iota // 0
+	// Maker indicates that a transaction added liquidity by resting on the book.
+	Maker
+)
+
 // Status variables refer to a status of an order's execution.
 type Status int
 
@@ -0,0 +1,217 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoMark is returned when a portfolio is valued without a quote for one of its holdings.
+var ErrNoMark = errors.New("no mark found for holding")
+
+// Portfolio is a collection of holdings keyed by name, along with a cash balance.
+// New holdings relieve their lots by the portfolio's Method; holdings using SpecificID
+// are closed through ApplyLots.
+type Portfolio struct {
+	Cash     Amount
+	Holdings map[string]*Holding
+	Method   LotMethod
+}
+
+// NewPortfolio returns a new portfolio instance funded with an amount of cash.
+func NewPortfolio(cash Amount) *Portfolio {
+	return &Portfolio{Cash: cash, Holdings: make(map[string]*Holding)}
+}
+
+// Deposit adds an amount of cash to a portfolio.
+func (p *Portfolio) Deposit(amt Amount) {
+	p.Cash += amt
+}
+
+// Withdraw removes an amount of cash from a portfolio.
+func (p *Portfolio) Withdraw(amt Amount) {
+	p.Cash -= amt
+}
+
+// Apply a transaction to the portfolio's holding of the same name, opening one if needed.
+// Cash is debited for the cost of buys and credited for the proceeds of sells, net of fees.
+func (p *Portfolio) Apply(tx Transaction) ([]LotRelief, error) {
+	var (
+		reliefs []LotRelief
+		err     error
+	)
+	h, ok := p.Holdings[tx.Name]
+	switch {
+	case ok:
+		reliefs, err = h.Apply(tx)
+	case tx.Buy:
+		h, err = Buy(tx)
+	default:
+		h, err = SellShort(tx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if p.Holdings == nil {
+			p.Holdings = make(map[string]*Holding)
+		}
+		h.Method = p.Method
+		p.Holdings[tx.Name] = h
+	}
+	p.Cash += cashFlow(tx)
+	return reliefs, nil
+}
+
+// ApplyLots closes part of the portfolio's holding of the same name from transaction data,
+// relieving the lots identified by ids in the order given. Cash is booked as with Apply.
+func (p *Portfolio) ApplyLots(tx Transaction, ids ...int) ([]LotRelief, error) {
+	h, ok := p.Holdings[tx.Name]
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidTx, "no holding of %s", tx.Name)
+	}
+	reliefs, err := h.SellOffLots(tx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	p.Cash += cashFlow(tx)
+	return reliefs, nil
+}
+
+// cashFlow returns the change in cash caused by a transaction, including its fee.
+func cashFlow(tx Transaction) Amount {
+	if tx.Buy {
+		return -NewAmount(tx.Price, tx.Volume) - tx.Fee
+	}
+	return NewAmount(tx.Price, tx.Volume) - tx.Fee
+}
+
+// Names returns the sorted names of a portfolio's open holdings.
+func (p *Portfolio) Names() []string {
+	names := make([]string, 0, len(p.Holdings))
+	for name, h := range p.Holdings {
+		if h.Volume != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Realized returns the gains realized across all of a portfolio's holdings.
+func (p *Portfolio) Realized() (amt Amount) {
+	for _, h := range p.Holdings {
+		amt += h.Realized()
+	}
+	return amt
+}
+
+// ----------------------------------------------------------------------------
+
+// Values returns the signed market value of each open holding marked at its quote.
+// Short holdings have a negative value.
+func (p *Portfolio) Values(marks map[string]Quote) (map[string]Amount, error) {
+	values := make(map[string]Amount, len(p.Holdings))
+	for _, name := range p.Names() {
+		q, ok := marks[name]
+		if !ok {
+			return nil, errors.Wrap(ErrNoMark, name)
+		}
+		mark, err := markPrice(q)
+		if err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		h := p.Holdings[name]
+		values[name] = NewAmount(mark, h.Volume)
+		if h.Short {
+			values[name] = -values[name]
+		}
+	}
+	return values, nil
+}
+
+// NAV returns the net asset value of a portfolio: its cash plus the market value of its holdings.
+func (p *Portfolio) NAV(marks map[string]Quote) (Amount, error) {
+	values, err := p.Values(marks)
+	if err != nil {
+		return 0, err
+	}
+	nav := p.Cash
+	for _, v := range values {
+		nav += v
+	}
+	return nav, nil
+}
+
+// Exposure returns the gross exposure of a portfolio, the sum of the absolute value of its
+// holdings, and its net exposure, long value less short value.
+func (p *Portfolio) Exposure(marks map[string]Quote) (gross, net Amount, err error) {
+	values, err := p.Values(marks)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, v := range values {
+		net += v
+		if v < 0 {
+			v = -v
+		}
+		gross += v
+	}
+	return gross, net, nil
+}
+
+// Weights returns the signed weight of each open holding as a percentage of the portfolio's NAV,
+// in hundredths of a percent.
+func (p *Portfolio) Weights(marks map[string]Quote) (map[string]Amount, error) {
+	values, err := p.Values(marks)
+	if err != nil {
+		return nil, err
+	}
+	nav := p.Cash
+	for _, v := range values {
+		nav += v
+	}
+	if nav == 0 {
+		return nil, errors.Wrap(ErrZeroValue, "portfolio NAV")
+	}
+	weights := make(map[string]Amount, len(values))
+	for name, v := range values {
+		weights[name] = roundDiv(v*10000, nav)
+	}
+	return weights, nil
+}
+
+// markPrice returns the price at which a quote values a holding:
+// the midpoint of its bid and ask, or whichever side is quoted.
+func markPrice(q Quote) (Price, error) {
+	if mid, err := q.Mid(); err == nil {
+		return mid, nil
+	}
+	switch {
+	case q.Bid.Price != 0:
+		return q.Bid.Price, nil
+	case q.Ask.Price != 0:
+		return q.Ask.Price, nil
+	}
+	return 0, ErrNilValue
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func mockPortfolioTx(name string, buy bool, price float64, vol uint32, fee Amount) Transaction {
+	return Transaction{
+		Name:         name,
+		Buy:          buy,
+		QuotedMetric: QuotedMetric{NewPrice(price), NewVolume(vol)},
+		Timestamp:    time.Time{},
+		Fee:          fee,
+	}
+}
+
+func mockMark(name string, price float64) Quote {
+	return Quote{
+		Name: name,
+		Bid:  QuotedMetric{NewPrice(price - 0.01), NewVolume(100)},
+		Ask:  QuotedMetric{NewPrice(price + 0.01), NewVolume(100)},
+	}
+}
+
+func mockPortfolio() *Portfolio {
+	p := NewPortfolio(NewAmount(NewPrice(10000.00), 1))
+	p.Apply(mockPortfolioTx("AAPL", true, 100.00, 50, 100))
+	p.Apply(mockPortfolioTx("GOOGL", false, 50.00, 20, 100))
+	return p
+}
+
+func TestPortfolio_Apply(t *testing.T) {
+	p := mockPortfolio()
+
+	if want := NewAmount(NewPrice(10000.00-5000.00+1000.00), 1) - 200; p.Cash != want {
+		t.Errorf("Portfolio.Cash = %v, want %v", p.Cash, want)
+	}
+	if got, want := p.Names(), []string{"AAPL", "GOOGL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Portfolio.Names() = %v, want %v", got, want)
+	}
+	if !p.Holdings["GOOGL"].Short {
+		t.Errorf("Portfolio.Apply() sell into empty holding should open a short")
+	}
+
+	reliefs, err := p.Apply(mockPortfolioTx("AAPL", false, 110.00, 50, 0))
+	if err != nil {
+		t.Fatalf("Portfolio.Apply() error = %v", err)
+	}
+	if len(reliefs) != 1 || p.Realized() != NewAmount(NewPrice(10.00), 50)-100 {
+		t.Errorf("Portfolio.Apply() reliefs = %v, realized = %v", reliefs, p.Realized())
+	}
+	if got, want := p.Names(), []string{"GOOGL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Portfolio.Names() = %v, want %v", got, want)
+	}
+}
+
+func TestPortfolio_zeroValue(t *testing.T) {
+	var p Portfolio
+	if _, err := p.Apply(mockPortfolioTx("AAPL", true, 100.00, 10, 0)); err != nil {
+		t.Fatalf("Portfolio.Apply() error = %v", err)
+	}
+	if got, want := p.Names(), []string{"AAPL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Portfolio.Names() = %v, want %v", got, want)
+	}
+}
+
+func TestPortfolio_ApplyLots(t *testing.T) {
+	p := NewPortfolio(0)
+	p.Method = SpecificID
+	p.Apply(mockPortfolioTx("AAPL", true, 100.00, 10, 0))
+	p.Apply(mockPortfolioTx("AAPL", true, 120.00, 10, 0))
+
+	sell := mockPortfolioTx("AAPL", false, 130.00, 10, 50)
+	if _, err := p.Apply(sell); errors.Cause(err) != ErrInvalidTx {
+		t.Errorf("Portfolio.Apply() with SpecificID error = %v, want %v", err, ErrInvalidTx)
+	}
+	if _, err := p.ApplyLots(mockPortfolioTx("MSFT", false, 130.00, 10, 0), 1); errors.Cause(err) != ErrInvalidTx {
+		t.Errorf("Portfolio.ApplyLots() without holding error = %v, want %v", err, ErrInvalidTx)
+	}
+
+	reliefs, err := p.ApplyLots(sell, 2)
+	if err != nil {
+		t.Fatalf("Portfolio.ApplyLots() error = %v", err)
+	}
+	if len(reliefs) != 1 || reliefs[0].Lot != 2 {
+		t.Errorf("Portfolio.ApplyLots() reliefs = %v, want lot 2", reliefs)
+	}
+	if want := NewAmount(NewPrice(130.00-100.00-120.00), 10) - 50; p.Cash != want {
+		t.Errorf("Portfolio.Cash = %v, want %v", p.Cash, want)
+	}
+	if want := NewAmount(NewPrice(10.00), 10) - 50; p.Realized() != want {
+		t.Errorf("Portfolio.Realized() = %v, want %v", p.Realized(), want)
+	}
+}
+
+func TestPortfolio_NAV(t *testing.T) {
+	marks := map[string]Quote{"AAPL": mockMark("AAPL", 110.00), "GOOGL": mockMark("GOOGL", 40.00)}
+
+	tests := []struct {
+		name    string
+		marks   map[string]Quote
+		want    Amount
+		wantErr error
+	}{
+		{"base case", marks, NewAmount(NewPrice(10000.00-5000.00+1000.00+5500.00-800.00), 1) - 200, nil},
+		{"missing mark", map[string]Quote{"AAPL": marks["AAPL"]}, 0, ErrNoMark},
+		{"empty mark", map[string]Quote{"AAPL": marks["AAPL"], "GOOGL": {Name: "GOOGL"}}, 0, ErrNilValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mockPortfolio().NAV(tt.marks)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("Portfolio.NAV() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Portfolio.NAV() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPortfolio_Exposure(t *testing.T) {
+	marks := map[string]Quote{"AAPL": mockMark("AAPL", 110.00), "GOOGL": mockMark("GOOGL", 40.00)}
+
+	gross, net, err := mockPortfolio().Exposure(marks)
+	if err != nil {
+		t.Fatalf("Portfolio.Exposure() error = %v", err)
+	}
+	if want := NewAmount(NewPrice(5500.00+800.00), 1); gross != want {
+		t.Errorf("Portfolio.Exposure() gross = %v, want %v", gross, want)
+	}
+	if want := NewAmount(NewPrice(5500.00-800.00), 1); net != want {
+		t.Errorf("Portfolio.Exposure() net = %v, want %v", net, want)
+	}
+}
+
+func TestPortfolio_Weights(t *testing.T) {
+	p := NewPortfolio(NewAmount(NewPrice(1000.00), 1))
+	p.Apply(mockPortfolioTx("AAPL", true, 10.00, 25, 0))
+	p.Apply(mockPortfolioTx("GOOGL", false, 10.00, 25, 0))
+	marks := map[string]Quote{"AAPL": mockMark("AAPL", 10.00), "GOOGL": mockMark("GOOGL", 10.00)}
+
+	got, err := p.Weights(marks)
+	if err != nil {
+		t.Fatalf("Portfolio.Weights() error = %v", err)
+	}
+	want := map[string]Amount{"AAPL": 2500, "GOOGL": -2500}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Portfolio.Weights() = %v, want %v", got, want)
+	}
+	if got["AAPL"].ToPercent() != "25.00%" {
+		t.Errorf("Portfolio.Weights() AAPL = %v, want 25.00%%", got["AAPL"].ToPercent())
+	}
+}
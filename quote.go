@@ -26,8 +26,9 @@ import (
 )
 
 var (
-	ErrZeroValue = errors.New("zero value found")
-	ErrNilValue  = errors.New("nil value found")
+	ErrZeroValue    = errors.New("zero value found")
+	ErrNilValue     = errors.New("nil value found")
+	ErrInvalidQuote = errors.New("invalid quote given")
 )
 
 // ----------------------------------------------------------------------------
@@ -59,6 +60,102 @@ func (q *Quote) TotalBid() (Amount, error) {
 	return q.Bid.Total()
 }
 
+// Mid returns the price halfway between a quote's bid and ask.
+func (q *Quote) Mid() (Price, error) {
+	if q.Bid.Price == 0 || q.Ask.Price == 0 {
+		return 0, ErrNilValue
+	}
+	return midpoint(q.Bid.Price, q.Ask.Price), nil
+}
+
+// Microprice returns the mid of a quote weighted by the volume of the opposite side,
+// leaning toward the side more likely to trade next.
+func (q *Quote) Microprice() (Price, error) {
+	if q.Bid.Price == 0 || q.Ask.Price == 0 {
+		return 0, ErrNilValue
+	}
+	total := Amount(q.Bid.Volume) + Amount(q.Ask.Volume)
+	if total == 0 {
+		return 0, ErrZeroValue
+	}
+	return Price(roundDiv(NewAmount(q.Bid.Price, q.Ask.Volume)+NewAmount(q.Ask.Price, q.Bid.Volume), total)), nil
+}
+
+// Spread returns the difference between a quote's ask and bid.
+func (q *Quote) Spread() (Price, error) {
+	if q.Bid.Price == 0 || q.Ask.Price == 0 {
+		return 0, ErrNilValue
+	}
+	return q.Ask.Price - q.Bid.Price, nil
+}
+
+// SpreadBps returns a quote's spread in basis points of its mid,
+// equivalently in hundredths of a percent.
+func (q *Quote) SpreadBps() (Amount, error) {
+	spread, err := q.Spread()
+	if err != nil {
+		return 0, err
+	}
+	return toBasisPoints(spread, midpoint(q.Bid.Price, q.Ask.Price))
+}
+
+// Imbalance returns the difference between a quote's bid and ask volume as a percentage of
+// their total, in hundredths of a percent; positive values indicate more volume bid.
+func (q *Quote) Imbalance() (Amount, error) {
+	total := Amount(q.Bid.Volume) + Amount(q.Ask.Volume)
+	if total == 0 {
+		return 0, ErrZeroValue
+	}
+	return roundDiv((Amount(q.Bid.Volume)-Amount(q.Ask.Volume))*10000, total), nil
+}
+
+// EffectiveSpread returns twice the signed distance of a transaction's price from the
+// mid of the quote prevailing when it traded: positive when a buy paid above, or a sell
+// received below, the mid.
+func (q *Quote) EffectiveSpread(tx *Transaction) (Price, error) {
+	mid, err := q.Mid()
+	if err != nil {
+		return 0, err
+	}
+	return 2 * direction(tx) * (tx.Price - mid), nil
+}
+
+// RealizedSpread returns twice the signed distance of a transaction's price from the mid
+// of a quote some time after it traded, such as five minutes later: the part of the
+// effective spread earned by the transaction's counterparty once prices have moved.
+func (q *Quote) RealizedSpread(tx *Transaction) (Price, error) {
+	return q.EffectiveSpread(tx)
+}
+
+// direction returns 1 for a buy and -1 for a sell.
+func direction(tx *Transaction) Price {
+	if tx.Buy {
+		return 1
+	}
+	return -1
+}
+
+// toBasisPoints returns a price as a number of basis points of another.
+func toBasisPoints(p, of Price) (Amount, error) {
+	if of == 0 {
+		return 0, ErrZeroValue
+	}
+	return roundDiv(Amount(p)*10000, Amount(of)), nil
+}
+
+// midpoint returns the price halfway between a bid and an ask, rounded to the nearest cent.
+func midpoint(bid, ask Price) Price {
+	return Price(roundDiv(Amount(bid+ask), 2))
+}
+
+// touch returns the side of a quote that a buy or sell order would execute against.
+func (q *Quote) touch(buy bool) QuotedMetric {
+	if buy {
+		return q.Ask
+	}
+	return q.Bid
+}
+
 // ----------------------------------------------------------------------------
 
 // A QuotedMetric is a representation of a Price with an associated Volume.
@@ -189,3 +189,68 @@ func TestNewQuotedMetric(t *testing.T) {
 		})
 	}
 }
+
+func TestQuote_derived(t *testing.T) {
+	q := Quote{Name: "AAPL", Bid: QuotedMetric{9990, 300}, Ask: QuotedMetric{10010, 100}}
+	oneSided := Quote{Name: "AAPL", Bid: QuotedMetric{9990, 300}}
+	noVolume := Quote{Name: "AAPL", Bid: QuotedMetric{Price: 9990}, Ask: QuotedMetric{Price: 10010}}
+
+	tests := []struct {
+		name    string
+		fn      func(q *Quote) (interface{}, error)
+		q       Quote
+		want    interface{}
+		wantErr error
+	}{
+		{"mid", func(q *Quote) (interface{}, error) { return q.Mid() }, q, Price(10000), nil},
+		{"mid one sided", func(q *Quote) (interface{}, error) { return q.Mid() }, oneSided, Price(0), ErrNilValue},
+		{"microprice", func(q *Quote) (interface{}, error) { return q.Microprice() }, q, Price(10005), nil},
+		{"microprice no volume", func(q *Quote) (interface{}, error) { return q.Microprice() }, noVolume, Price(0), ErrZeroValue},
+		{"spread", func(q *Quote) (interface{}, error) { return q.Spread() }, q, Price(20), nil},
+		{"spread one sided", func(q *Quote) (interface{}, error) { return q.Spread() }, oneSided, Price(0), ErrNilValue},
+		{"spread bps", func(q *Quote) (interface{}, error) { return q.SpreadBps() }, q, Amount(20), nil},
+		{"imbalance", func(q *Quote) (interface{}, error) { return q.Imbalance() }, q, Amount(5000), nil},
+		{"imbalance no volume", func(q *Quote) (interface{}, error) { return q.Imbalance() }, noVolume, Amount(0), ErrZeroValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.fn(&tt.q)
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuote_EffectiveSpread(t *testing.T) {
+	prevailing := Quote{Name: "AAPL", Bid: QuotedMetric{9990, 100}, Ask: QuotedMetric{10010, 100}}
+	later := Quote{Name: "AAPL", Bid: QuotedMetric{10000, 100}, Ask: QuotedMetric{10020, 100}}
+	buy := &Transaction{Name: "AAPL", Buy: true, QuotedMetric: QuotedMetric{10010, 100}}
+	sell := &Transaction{Name: "AAPL", QuotedMetric: QuotedMetric{9995, 100}}
+
+	tests := []struct {
+		name string
+		q    Quote
+		tx   *Transaction
+		fn   func(q *Quote, tx *Transaction) (Price, error)
+		want Price
+	}{
+		{"effective buy", prevailing, buy, (*Quote).EffectiveSpread, 20},
+		{"effective sell", prevailing, sell, (*Quote).EffectiveSpread, 10},
+		{"realized buy", later, buy, (*Quote).RealizedSpread, 0},
+		{"realized sell", later, sell, (*Quote).RealizedSpread, 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tt.fn(&tt.q, tt.tx); err != nil || got != tt.want {
+				t.Errorf("got %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+	if _, err := (&Quote{}).EffectiveSpread(buy); err != ErrNilValue {
+		t.Errorf("EffectiveSpread() of empty quote error = %v, want %v", err, ErrNilValue)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrOutOfOrder is returned when a transaction or quote is timestamped before one already processed.
+var ErrOutOfOrder = errors.New("out of timestamp order")
+
+// Snapshot is the state of a portfolio after a number of transactions have been applied to it.
+// Timestamp is that of the last transaction applied.
+type Snapshot struct {
+	Seq       int
+	Timestamp time.Time
+	Portfolio *Portfolio
+}
+
+// Replay rebuilds a portfolio by applying an ordered log of transactions to a copy of an initial one.
+func Replay(initial *Portfolio, txs []Transaction) (*Portfolio, error) {
+	return ReplayFrom(Snapshot{Portfolio: initial}, txs)
+}
+
+// ReplayFrom rebuilds a portfolio by applying the transactions that followed a snapshot
+// to a copy of its portfolio.
+func ReplayFrom(s Snapshot, txs []Transaction) (*Portfolio, error) {
+	p, last := s.Portfolio.Clone(), s.Timestamp
+	for i, tx := range txs {
+		if tx.Timestamp.Before(last) {
+			return nil, errors.Wrapf(ErrOutOfOrder, "transaction %d", s.Seq+i)
+		}
+		if _, err := p.Apply(tx); err != nil {
+			return nil, errors.Wrapf(err, "transaction %d", s.Seq+i)
+		}
+		last = tx.Timestamp
+	}
+	return p, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Replayer incrementally applies a log of transactions to a portfolio,
+// snapshotting its state every Interval transactions so that it can be
+// reconstructed as of any point in time without replaying the whole log.
+type Replayer struct {
+	Interval int
+
+	log       []Transaction
+	state     *Portfolio
+	snapshots []Snapshot
+}
+
+// NewReplayer returns a new replayer instance starting from a copy of an initial portfolio.
+// A zero interval only snapshots the initial portfolio.
+func NewReplayer(initial *Portfolio, interval int) *Replayer {
+	return &Replayer{
+		Interval:  interval,
+		state:     initial.Clone(),
+		snapshots: []Snapshot{{Portfolio: initial.Clone()}},
+	}
+}
+
+// Append applies transactions to a replayer's portfolio and adds them to its log.
+// Transactions must not be timestamped before those already appended.
+func (r *Replayer) Append(txs ...Transaction) error {
+	for _, tx := range txs {
+		seq := len(r.log)
+		if seq > 0 && tx.Timestamp.Before(r.log[seq-1].Timestamp) {
+			return errors.Wrapf(ErrOutOfOrder, "transaction %d", seq)
+		}
+		if _, err := r.state.Apply(tx); err != nil {
+			return errors.Wrapf(err, "transaction %d", seq)
+		}
+		r.log = append(r.log, tx)
+		if r.Interval > 0 && len(r.log)%r.Interval == 0 {
+			r.snapshots = append(r.snapshots, r.Snapshot())
+		}
+	}
+	return nil
+}
+
+// Portfolio returns the replayer's current portfolio.
+// It must not be modified outside of Append.
+func (r *Replayer) Portfolio() *Portfolio {
+	return r.state
+}
+
+// Log returns the transactions appended to a replayer.
+func (r *Replayer) Log() []Transaction {
+	return r.log
+}
+
+// Snapshot returns a copy of the replayer's current state.
+func (r *Replayer) Snapshot() Snapshot {
+	s := Snapshot{Seq: len(r.log), Portfolio: r.state.Clone()}
+	if s.Seq > 0 {
+		s.Timestamp = r.log[s.Seq-1].Timestamp
+	}
+	return s
+}
+
+// AsOf reconstructs the portfolio held at a time, after applying every transaction
+// timestamped at or before it. Replay starts from the latest snapshot taken by then.
+func (r *Replayer) AsOf(t time.Time) (*Portfolio, error) {
+	i := sort.Search(len(r.snapshots), func(i int) bool {
+		return r.snapshots[i].Seq > 0 && r.snapshots[i].Timestamp.After(t)
+	})
+	s := r.snapshots[i-1]
+
+	end := s.Seq + sort.Search(len(r.log)-s.Seq, func(i int) bool {
+		return r.log[s.Seq+i].Timestamp.After(t)
+	})
+	return ReplayFrom(s, r.log[s.Seq:end])
+}
+
+// ----------------------------------------------------------------------------
+
+// Clone returns a deep copy of a portfolio.
+func (p *Portfolio) Clone() *Portfolio {
+	c := *p
+	c.Holdings = make(map[string]*Holding, len(p.Holdings))
+	for name, h := range p.Holdings {
+		c.Holdings[name] = h.Clone()
+	}
+	return &c
+}
+
+// Clone returns a deep copy of a holding.
+func (h *Holding) Clone() *Holding {
+	c := *h
+	if h.Lots != nil {
+		c.Lots = make([]Lot, len(h.Lots))
+		copy(c.Lots, h.Lots)
+	}
+	return &c
+}
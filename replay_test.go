@@ -0,0 +1,122 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func mockReplayLog() []Transaction {
+	day := func(d int) time.Time { return time.Date(2017, 1, d, 0, 0, 0, 0, time.UTC) }
+	return []Transaction{
+		{Name: "AAPL", Buy: true, QuotedMetric: QuotedMetric{NewPrice(100.00), 10}, Timestamp: day(2)},
+		{Name: "GOOGL", Buy: true, QuotedMetric: QuotedMetric{NewPrice(50.00), 10}, Timestamp: day(2)},
+		{Name: "AAPL", Buy: true, QuotedMetric: QuotedMetric{NewPrice(110.00), 10}, Timestamp: day(3)},
+		{Name: "AAPL", Buy: false, QuotedMetric: QuotedMetric{NewPrice(120.00), 15}, Timestamp: day(4)},
+		{Name: "GOOGL", Buy: false, QuotedMetric: QuotedMetric{NewPrice(55.00), 10}, Timestamp: day(5)},
+	}
+}
+
+func TestReplay(t *testing.T) {
+	log := mockReplayLog()
+
+	want := NewPortfolio(NewAmount(NewPrice(5000.00), 1))
+	for _, tx := range log {
+		want.Apply(tx)
+	}
+	got, err := Replay(NewPortfolio(NewAmount(NewPrice(5000.00), 1)), log)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Replay() = %v, want %v", got, want)
+	}
+
+	unordered := []Transaction{log[2], log[0]}
+	if _, err = Replay(NewPortfolio(0), unordered); errors.Cause(err) != ErrOutOfOrder {
+		t.Errorf("Replay() error = %v, want %v", err, ErrOutOfOrder)
+	}
+}
+
+func TestReplayer_AsOf(t *testing.T) {
+	log := mockReplayLog()
+	cash := NewAmount(NewPrice(5000.00), 1)
+
+	for _, interval := range []int{0, 1, 2} {
+		r := NewReplayer(NewPortfolio(cash), interval)
+		if err := r.Append(log[:3]...); err != nil {
+			t.Fatalf("Replayer.Append() error = %v", err)
+		}
+		if err := r.Append(log[3:]...); err != nil {
+			t.Fatalf("Replayer.Append() error = %v", err)
+		}
+
+		tests := []struct {
+			name string
+			asOf time.Time
+			n    int
+		}{
+			{"before log", time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), 0},
+			{"same timestamp", log[1].Timestamp, 2},
+			{"between", log[3].Timestamp.Add(time.Hour), 4},
+			{"after log", log[4].Timestamp.Add(time.Hour), 5},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				want, _ := Replay(NewPortfolio(cash), log[:tt.n])
+				got, err := r.AsOf(tt.asOf)
+				if err != nil {
+					t.Fatalf("Replayer.AsOf() error = %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("interval %d: Replayer.AsOf() = %v, want %v", interval, got, want)
+				}
+			})
+		}
+
+		if !reflect.DeepEqual(r.Snapshot().Portfolio, r.Portfolio()) || r.Snapshot().Seq != len(log) {
+			t.Errorf("interval %d: Replayer.Snapshot() does not match current state", interval)
+		}
+	}
+}
+
+func TestReplayFrom(t *testing.T) {
+	log := mockReplayLog()
+	r := NewReplayer(NewPortfolio(0), 0)
+	r.Append(log[:2]...)
+	s := r.Snapshot()
+	r.Append(log[2:]...)
+
+	got, err := ReplayFrom(s, log[2:])
+	if err != nil {
+		t.Fatalf("ReplayFrom() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, r.Portfolio()) {
+		t.Errorf("ReplayFrom() = %v, want %v", got, r.Portfolio())
+	}
+	if s.Portfolio.Holdings["AAPL"].Volume != 10 {
+		t.Errorf("ReplayFrom() modified its snapshot")
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoLiquidity is returned when a quote has no volume on the side an order would fill against.
+var ErrNoLiquidity = errors.New("no liquidity found at quote")
+
+// SlippageModel estimates the adverse price movement incurred by filling a volume against a quote.
+// Implementations return a non-negative per-unit Price; the direction is applied by the caller.
+type SlippageModel interface {
+	Slippage(q *Quote, buy bool, vol Volume) Price
+}
+
+// NoSlippage fills every order at the touch.
+type NoSlippage struct{}
+
+// Slippage always returns zero.
+func (NoSlippage) Slippage(q *Quote, buy bool, vol Volume) Price {
+	return 0
+}
+
+// FixedBps charges a constant number of basis points of the touch price.
+type FixedBps struct {
+	Bps float64
+}
+
+// Slippage returns Bps basis points of the touch price.
+func (m FixedBps) Slippage(q *Quote, buy bool, vol Volume) Price {
+	return bpsOf(q.touch(buy).Price, m.Bps)
+}
+
+// VolumeParticipation scales impact linearly with the share of quoted volume consumed.
+// Bps is the impact charged when an order takes all of the volume at the touch.
+type VolumeParticipation struct {
+	Bps float64
+}
+
+// Slippage returns Bps scaled by the participation rate of vol at the touch.
+func (m VolumeParticipation) Slippage(q *Quote, buy bool, vol Volume) Price {
+	side := q.touch(buy)
+	if side.Volume == 0 {
+		return 0
+	}
+	return bpsOf(side.Price, m.Bps*participation(vol, side.Volume))
+}
+
+// SquareRootImpact models impact as Coefficient * Volatility * sqrt(participation),
+// expressed as a fraction of the touch price.
+type SquareRootImpact struct {
+	Coefficient float64
+	Volatility  float64
+}
+
+// Slippage returns the square-root impact of filling vol at the touch.
+func (m SquareRootImpact) Slippage(q *Quote, buy bool, vol Volume) Price {
+	side := q.touch(buy)
+	if side.Volume == 0 {
+		return 0
+	}
+	impact := m.Coefficient * m.Volatility * math.Sqrt(participation(vol, side.Volume))
+	return Price(math.Round(float64(side.Price) * impact))
+}
+
+// SpreadCrossing charges a fraction of the quoted bid-ask spread.
+type SpreadCrossing struct {
+	Fraction float64
+}
+
+// Slippage returns Fraction of the quote's spread.
+func (m SpreadCrossing) Slippage(q *Quote, buy bool, vol Volume) Price {
+	if q.Ask.Price <= q.Bid.Price {
+		return 0
+	}
+	return Price(math.Round(float64(q.Ask.Price-q.Bid.Price) * m.Fraction))
+}
+
+// bpsOf returns the number of basis points of a price, rounded to the nearest cent.
+func bpsOf(p Price, bps float64) Price {
+	return Price(math.Round(float64(p) * bps / 10000))
+}
+
+// participation returns the fraction of available volume consumed by vol.
+func participation(vol, available Volume) float64 {
+	return float64(vol) / float64(available)
+}
+
+// ----------------------------------------------------------------------------
+
+// Fill is a Transaction produced by a FillSimulator, along with the slippage it incurred.
+type Fill struct {
+	Transaction
+	Slippage Price
+}
+
+// Cost returns the total amount lost to slippage on a fill.
+func (f *Fill) Cost() Amount {
+	return NewAmount(f.Slippage, f.Volume)
+}
+
+// FillSimulator converts orders into transactions against quoted prices.
+// When Fees is set, each transaction is charged under its schedule.
+type FillSimulator struct {
+	Model SlippageModel
+	Fees  FeeSchedule
+}
+
+// NewFillSimulator returns a new fill simulator using a slippage model.
+// A nil model fills every order at the touch.
+func NewFillSimulator(model SlippageModel) *FillSimulator {
+	if model == nil {
+		model = NoSlippage{}
+	}
+	return &FillSimulator{Model: model}
+}
+
+// Fill executes as much of an order's open volume as the quote allows.
+// A nil Fill with a nil error means the order is not marketable at the quote.
+func (fs *FillSimulator) Fill(o *Order, q *Quote) (*Fill, error) {
+	if o.Status != Open || o.Name != q.Name {
+		return nil, errors.Wrap(ErrInvalidOrder, "order cannot be filled by quote")
+	}
+	side := q.touch(o.Buy)
+	if side.Price == 0 || side.Volume == 0 {
+		return nil, ErrNoLiquidity
+	}
+	if o.Logic == Limit && !marketable(o.Buy, o.Price, side.Price) {
+		return nil, nil
+	}
+
+	vol := o.Remaining()
+	if vol > side.Volume {
+		vol = side.Volume
+	}
+	slip := fs.Model.Slippage(q, o.Buy, vol)
+	price := side.Price + slip
+	if !o.Buy {
+		price = side.Price - slip
+	}
+	if o.Logic == Limit && !marketable(o.Buy, o.Price, price) {
+		price = o.Price
+		slip = side.Price - price
+		if o.Buy {
+			slip = price - side.Price
+		}
+	}
+
+	o.filled += vol
+	if o.filled == o.Volume {
+		o.Status = Closed
+	}
+	fill := &Fill{
+		Transaction: Transaction{
+			Name:         o.Name,
+			Buy:          o.Buy,
+			QuotedMetric: QuotedMetric{Price: price, Volume: vol},
+			Timestamp:    q.Timestamp,
+			Liquidity:    Taker,
+		},
+		Slippage: slip,
+	}
+	if fs.Fees != nil {
+		fill.ApplyFee(fs.Fees)
+	}
+	return fill, nil
+}
+
+// marketable reports whether a limit price can trade at a given price.
+func marketable(buy bool, limit, price Price) bool {
+	if buy {
+		return price <= limit
+	}
+	return price >= limit
+}
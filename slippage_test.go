@@ -0,0 +1,123 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"testing"
+	"time"
+)
+
+func mockSpreadQuote() *Quote {
+	return &Quote{
+		Name:      "AAPL",
+		Bid:       QuotedMetric{NewPrice(99.00), NewVolume(100)},
+		Ask:       QuotedMetric{NewPrice(100.00), NewVolume(100)},
+		Timestamp: time.Date(2017, 1, 3, 9, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestSlippageModel_Slippage(t *testing.T) {
+	type args struct {
+		buy bool
+		vol Volume
+	}
+	tests := []struct {
+		name  string
+		model SlippageModel
+		args  args
+		want  Price
+	}{
+		{"no slippage", NoSlippage{}, args{true, 100}, 0},
+		{"fixed bps buy", FixedBps{Bps: 10}, args{true, 100}, NewPrice(0.10)},
+		{"fixed bps sell", FixedBps{Bps: 10}, args{false, 100}, NewPrice(0.10)},
+		{"full participation", VolumeParticipation{Bps: 20}, args{true, 100}, NewPrice(0.20)},
+		{"half participation", VolumeParticipation{Bps: 20}, args{true, 50}, NewPrice(0.10)},
+		{"square root", SquareRootImpact{Coefficient: 1, Volatility: 0.02}, args{true, 25}, NewPrice(1.00)},
+		{"half spread", SpreadCrossing{Fraction: 0.5}, args{true, 100}, NewPrice(0.50)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.model.Slippage(mockSpreadQuote(), tt.args.buy, tt.args.vol); got != tt.want {
+				t.Errorf("SlippageModel.Slippage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillSimulator_Fill(t *testing.T) {
+	q := mockSpreadQuote()
+
+	tests := []struct {
+		name      string
+		model     SlippageModel
+		o         *Order
+		wantPrice Price
+		wantVol   Volume
+		wantSlip  Price
+		wantNil   bool
+		wantErr   bool
+	}{
+		{"market buy", FixedBps{Bps: 10}, NewOrder("AAPL", true, Market, 0, 50, q.Timestamp), NewPrice(100.10), 50, NewPrice(0.10), false, false},
+		{"market sell", FixedBps{Bps: 10}, NewOrder("AAPL", false, Market, 0, 50, q.Timestamp), NewPrice(98.90), 50, NewPrice(0.10), false, false},
+		{"partial fill", nil, NewOrder("AAPL", true, Market, 0, 150, q.Timestamp), NewPrice(100.00), 100, 0, false, false},
+		{"limit not marketable", nil, NewOrder("AAPL", true, Limit, NewPrice(99.50), 50, q.Timestamp), 0, 0, 0, true, false},
+		{"limit caps slippage", FixedBps{Bps: 50}, NewOrder("AAPL", true, Limit, NewPrice(100.25), 50, q.Timestamp), NewPrice(100.25), 50, NewPrice(0.25), false, false},
+		{"wrong name", nil, NewOrder("GOOGL", true, Market, 0, 50, q.Timestamp), 0, 0, 0, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewFillSimulator(tt.model).Fill(tt.o, q)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FillSimulator.Fill() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got == nil {
+				if !tt.wantNil {
+					t.Errorf("FillSimulator.Fill() = nil, want fill")
+				}
+				return
+			}
+			if got.Price != tt.wantPrice || got.Volume != tt.wantVol || got.Slippage != tt.wantSlip {
+				t.Errorf("FillSimulator.Fill() = %v @ %v (slip %v), want %v @ %v (slip %v)",
+					got.Volume, got.Price, got.Slippage, tt.wantVol, tt.wantPrice, tt.wantSlip)
+			}
+			if !got.Timestamp.Equal(q.Timestamp) {
+				t.Errorf("FillSimulator.Fill() timestamp = %v, want %v", got.Timestamp, q.Timestamp)
+			}
+		})
+	}
+}
+
+func TestFillSimulator_Fill_closesOrder(t *testing.T) {
+	q := mockSpreadQuote()
+	o := NewOrder("AAPL", true, Market, 0, 150, q.Timestamp)
+	fs := NewFillSimulator(nil)
+
+	if _, err := fs.Fill(o, q); err != nil || o.Status != Open || o.Remaining() != 50 {
+		t.Fatalf("first fill: err = %v, status = %v, remaining = %v", err, o.Status, o.Remaining())
+	}
+	if _, err := fs.Fill(o, q); err != nil || o.Status != Closed || o.Remaining() != 0 {
+		t.Fatalf("second fill: err = %v, status = %v, remaining = %v", err, o.Status, o.Remaining())
+	}
+	if _, err := fs.Fill(o, q); err == nil {
+		t.Errorf("FillSimulator.Fill() on closed order: expected error")
+	}
+}
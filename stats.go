@@ -0,0 +1,76 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"math"
+	"time"
+)
+
+// RunningStat accumulates statistics of a price series one observation at a time.
+// Means are kept exact from a running total; variance uses Welford's method.
+type RunningStat struct {
+	N        uint
+	Last     SummaryMetric
+	Max, Min SummaryMetric
+
+	sum      Amount
+	mean, m2 float64
+}
+
+// Add a price observed at a time to a running statistic.
+func (r *RunningStat) Add(p Price, t time.Time) {
+	if r.N == 0 {
+		r.Max = SummaryMetric{Price: p, Date: t}
+		r.Min = SummaryMetric{Price: p, Date: t}
+	} else {
+		r.Max.Max(p, t)
+		r.Min.Min(p, t)
+	}
+	r.Last = SummaryMetric{Price: p, Date: t}
+	r.N++
+	r.sum += Amount(p)
+
+	delta := float64(p) - r.mean
+	r.mean += delta / float64(r.N)
+	r.m2 += delta * (float64(p) - r.mean)
+}
+
+// Mean returns the average of the prices observed, rounded to the nearest cent.
+func (r *RunningStat) Mean() Price {
+	if r.N == 0 {
+		return 0
+	}
+	return Price(roundDiv(r.sum, Amount(r.N)))
+}
+
+// Variance returns the sample variance of the prices observed, in squared cents.
+func (r *RunningStat) Variance() float64 {
+	if r.N < 2 {
+		return 0
+	}
+	return r.m2 / float64(r.N-1)
+}
+
+// StdDev returns the sample standard deviation of the prices observed.
+func (r *RunningStat) StdDev() Price {
+	return Price(math.Round(math.Sqrt(r.Variance())))
+}
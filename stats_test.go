@@ -0,0 +1,66 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRunningStat_Add(t *testing.T) {
+	at := func(s int) time.Time { return time.Date(2017, 1, 3, 9, 30, s, 0, time.UTC) }
+
+	tests := []struct {
+		name       string
+		prices     []Price
+		wantMean   Price
+		wantVar    float64
+		wantStdDev Price
+		wantMax    SummaryMetric
+		wantMin    SummaryMetric
+	}{
+		{"empty", nil, 0, 0, 0, SummaryMetric{}, SummaryMetric{}},
+		{"single", []Price{1000}, 1000, 0, 0, SummaryMetric{1000, at(0)}, SummaryMetric{1000, at(0)}},
+		{"series", []Price{200, 400, 400, 400, 500, 500, 700, 900}, 500, 320000.0 / 7, 214, SummaryMetric{900, at(7)}, SummaryMetric{200, at(0)}},
+		{"repeated extremes keep first date", []Price{300, 100, 300, 100}, 200, 40000.0 / 3, 115, SummaryMetric{300, at(0)}, SummaryMetric{100, at(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r RunningStat
+			for i, p := range tt.prices {
+				r.Add(p, at(i))
+			}
+			if got := r.Mean(); got != tt.wantMean {
+				t.Errorf("RunningStat.Mean() = %v, want %v", got, tt.wantMean)
+			}
+			if got := r.Variance(); math.Abs(got-tt.wantVar) > 1e-9 {
+				t.Errorf("RunningStat.Variance() = %v, want %v", got, tt.wantVar)
+			}
+			if got := r.StdDev(); got != tt.wantStdDev {
+				t.Errorf("RunningStat.StdDev() = %v, want %v", got, tt.wantStdDev)
+			}
+			if r.Max != tt.wantMax || r.Min != tt.wantMin {
+				t.Errorf("RunningStat max = %v min = %v, want %v and %v", r.Max, r.Min, tt.wantMax, tt.wantMin)
+			}
+		})
+	}
+}
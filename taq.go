@@ -0,0 +1,243 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TradeSide refers to the side that initiated a trade.
+type TradeSide int
+
+const (
+	// Unclassified indicates that a trade's initiator could not be determined.
+	Unclassified TradeSide = iota // 0
+	// BuyerInitiated indicates that a trade was initiated by its buyer.
+	BuyerInitiated
+	// SellerInitiated indicates that a trade was initiated by its seller.
+	SellerInitiated
+)
+
+// ClassifyMethod refers to the algorithm used to determine a trade's initiator.
+type ClassifyMethod int
+
+const (
+	// LeeReady classifies trades by the quote rule, falling back to the tick test
+	// for trades at the midpoint.
+	LeeReady ClassifyMethod = iota // 0
+	// TickTest classifies trades above the previous trade price of their name as buys and
+	// below it as sells; trades at the previous price take the last price change's side.
+	TickTest
+	// QuoteRule classifies trades above the prevailing midpoint as buys and below it as sells.
+	QuoteRule
+)
+
+// ----------------------------------------------------------------------------
+
+// QuoteHistory is a history of quotes per name, in timestamp order, for as-of lookups.
+type QuoteHistory struct {
+	quotes map[string][]Quote
+}
+
+// NewQuoteHistory returns a new, empty quote history.
+func NewQuoteHistory() *QuoteHistory {
+	return &QuoteHistory{quotes: make(map[string][]Quote)}
+}
+
+// Add a quote to a history. Quotes of a name must be added in timestamp order.
+func (h *QuoteHistory) Add(q Quote) error {
+	quotes := h.quotes[q.Name]
+	if n := len(quotes); n != 0 && q.Timestamp.Before(quotes[n-1].Timestamp) {
+		return errors.Wrapf(ErrOutOfOrder, "%s quote at %s", q.Name, q.Timestamp)
+	}
+	h.quotes[q.Name] = append(quotes, q)
+	return nil
+}
+
+// AsOf returns the latest quote of a name at or before a time.
+func (h *QuoteHistory) AsOf(name string, t time.Time) (Quote, bool) {
+	quotes := h.quotes[name]
+	i := sort.Search(len(quotes), func(i int) bool { return quotes[i].Timestamp.After(t) })
+	if i == 0 {
+		return Quote{}, false
+	}
+	return quotes[i-1], true
+}
+
+// Len returns the number of quotes held for a name.
+func (h *QuoteHistory) Len(name string) int {
+	return len(h.quotes[name])
+}
+
+// trim drops the quotes of a name that can no longer prevail at or after a time.
+func (h *QuoteHistory) trim(name string, t time.Time) {
+	quotes := h.quotes[name]
+	i := sort.Search(len(quotes), func(i int) bool { return quotes[i].Timestamp.After(t) })
+	if i > 1 {
+		h.quotes[name] = append(quotes[:0], quotes[i-1:]...)
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// AlignedTrade is a transaction aligned with the quote prevailing when it traded,
+// along with the side classified as its initiator. Matched is false if no quote prevailed.
+type AlignedTrade struct {
+	Transaction
+	Quote   Quote
+	Matched bool
+	Side    TradeSide
+}
+
+// EffectiveSpread returns the effective spread of an aligned trade against its prevailing
+// quote, signed by its classified side, or by its Buy field if it is unclassified.
+func (a *AlignedTrade) EffectiveSpread() (Price, error) {
+	if !a.Matched {
+		return 0, ErrNilValue
+	}
+	tx := a.Transaction
+	if a.Side != Unclassified {
+		tx.Buy = a.Side == BuyerInitiated
+	}
+	return a.Quote.EffectiveSpread(&tx)
+}
+
+// TradeClassifier aligns a stream of transactions with a stream of quotes and classifies
+// their initiators. Each transaction is aligned with the latest quote of its name at or
+// before its timestamp less Delay, which allows for quotes reported ahead of trades.
+//
+// Quotes and transactions should be given in timestamp order; quotes of any name that can
+// no longer prevail for later transactions are discarded as quotes and transactions arrive,
+// bounding the classifier's memory even for names that never trade.
+type TradeClassifier struct {
+	Method ClassifyMethod
+	Delay  time.Duration
+
+	history *QuoteHistory
+	ticks   map[string]tickState
+	stream  bool
+	latest  time.Time
+}
+
+// tickState is the last trade price of a name and the side of its last price change.
+type tickState struct {
+	price Price
+	side  TradeSide
+}
+
+// NewTradeClassifier returns a new streaming trade classifier.
+func NewTradeClassifier(method ClassifyMethod, delay time.Duration) *TradeClassifier {
+	return &TradeClassifier{
+		Method:  method,
+		Delay:   delay,
+		history: NewQuoteHistory(),
+		ticks:   make(map[string]tickState),
+		stream:  true,
+	}
+}
+
+// AddQuote adds a quote to a classifier.
+func (c *TradeClassifier) AddQuote(q Quote) error {
+	if err := c.history.Add(q); err != nil {
+		return err
+	}
+	if c.stream && !c.latest.IsZero() {
+		c.history.trim(q.Name, c.latest)
+	}
+	return nil
+}
+
+// Classify aligns a transaction with its prevailing quote and classifies its initiator.
+func (c *TradeClassifier) Classify(tx Transaction) AlignedTrade {
+	asOf := tx.Timestamp.Add(-c.Delay)
+	a := AlignedTrade{Transaction: tx}
+	a.Quote, a.Matched = c.history.AsOf(tx.Name, asOf)
+	if c.stream {
+		if asOf.After(c.latest) {
+			c.latest = asOf
+		}
+		c.history.trim(tx.Name, c.latest)
+	}
+
+	tick := c.tick(tx)
+	switch c.Method {
+	case TickTest:
+		a.Side = tick
+	case QuoteRule:
+		a.Side = quoteRule(tx.Price, a.Quote)
+	default:
+		if a.Side = quoteRule(tx.Price, a.Quote); a.Side == Unclassified {
+			a.Side = tick
+		}
+	}
+	return a
+}
+
+// tick applies the tick test to a transaction, updating the last trade of its name.
+func (c *TradeClassifier) tick(tx Transaction) TradeSide {
+	last, ok := c.ticks[tx.Name]
+	next := tickState{price: tx.Price, side: last.side}
+	switch {
+	case !ok:
+		next.side = Unclassified
+	case tx.Price > last.price:
+		next.side = BuyerInitiated
+	case tx.Price < last.price:
+		next.side = SellerInitiated
+	}
+	c.ticks[tx.Name] = next
+	return next.side
+}
+
+// quoteRule classifies a trade price against a quote's midpoint.
+func quoteRule(p Price, q Quote) TradeSide {
+	mid, err := q.Mid()
+	switch {
+	case err != nil || p == mid:
+		return Unclassified
+	case p > mid:
+		return BuyerInitiated
+	}
+	return SellerInitiated
+}
+
+// Align aligns a batch of transactions with a quote history and classifies their initiators.
+// Transactions are classified in timestamp order, for the tick test, and returned in the
+// order given.
+func Align(history *QuoteHistory, txs []Transaction, method ClassifyMethod, delay time.Duration) []AlignedTrade {
+	c := &TradeClassifier{Method: method, Delay: delay, history: history, ticks: make(map[string]tickState)}
+
+	order := make([]int, len(txs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return txs[order[i]].Timestamp.Before(txs[order[j]].Timestamp)
+	})
+	aligned := make([]AlignedTrade, len(txs))
+	for _, i := range order {
+		aligned[i] = c.Classify(txs[i])
+	}
+	return aligned
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func mockTAQ() ([]Quote, []Transaction) {
+	at := func(sec int) time.Time { return time.Date(2017, 1, 3, 9, 30, sec, 0, time.UTC) }
+	trade := func(name string, p Price, sec int) Transaction {
+		return Transaction{Name: name, QuotedMetric: QuotedMetric{p, 100}, Timestamp: at(sec)}
+	}
+	quotes := []Quote{
+		{Name: "AAPL", Bid: QuotedMetric{9990, 100}, Ask: QuotedMetric{10010, 100}, Timestamp: at(0)},
+		{Name: "AAPL", Bid: QuotedMetric{10000, 100}, Ask: QuotedMetric{10020, 100}, Timestamp: at(10)},
+	}
+	txs := []Transaction{
+		trade("AAPL", 10010, 5),
+		trade("AAPL", 10000, 6),
+		trade("MSFT", 5000, 7),
+		trade("AAPL", 10010, 12),
+		trade("AAPL", 10010, 13),
+		trade("AAPL", 10000, 14),
+	}
+	return quotes, txs
+}
+
+func mockQuoteHistory(quotes []Quote) *QuoteHistory {
+	h := NewQuoteHistory()
+	for _, q := range quotes {
+		if err := h.Add(q); err != nil {
+			panic(err)
+		}
+	}
+	return h
+}
+
+func sides(aligned []AlignedTrade) []TradeSide {
+	var s []TradeSide
+	for _, a := range aligned {
+		s = append(s, a.Side)
+	}
+	return s
+}
+
+func TestQuoteHistory_AsOf(t *testing.T) {
+	quotes, _ := mockTAQ()
+	h := mockQuoteHistory(quotes)
+	tests := []struct {
+		name   string
+		t      time.Time
+		want   Quote
+		wantOK bool
+	}{
+		{"before first", quotes[0].Timestamp.Add(-time.Second), Quote{}, false},
+		{"at first", quotes[0].Timestamp, quotes[0], true},
+		{"between", quotes[1].Timestamp.Add(-time.Second), quotes[0], true},
+		{"after last", quotes[1].Timestamp.Add(time.Hour), quotes[1], true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := h.AsOf("AAPL", tt.t)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("AsOf() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+	if err := h.Add(quotes[0]); errors.Cause(err) != ErrOutOfOrder {
+		t.Errorf("Add() of older quote error = %v, want %v", err, ErrOutOfOrder)
+	}
+}
+
+func TestAlign(t *testing.T) {
+	quotes, txs := mockTAQ()
+	B, S, U := BuyerInitiated, SellerInitiated, Unclassified
+	tests := []struct {
+		name   string
+		method ClassifyMethod
+		want   []TradeSide
+	}{
+		{"lee-ready", LeeReady, []TradeSide{B, S, U, B, B, S}},
+		{"tick test", TickTest, []TradeSide{U, S, U, B, B, S}},
+		{"quote rule", QuoteRule, []TradeSide{B, U, U, U, U, S}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sides(Align(mockQuoteHistory(quotes), txs, tt.method, 0)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Align() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Trades given out of order are classified in timestamp order but returned as given.
+	reversed := make([]Transaction, len(txs))
+	for i := range txs {
+		reversed[len(txs)-1-i] = txs[i]
+	}
+	got := sides(Align(mockQuoteHistory(quotes), reversed, TickTest, 0))
+	if want := []TradeSide{S, B, B, U, S, U}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Align() of reversed trades = %v, want %v", got, want)
+	}
+
+	// With a delay, trades align with earlier quotes.
+	aligned := Align(mockQuoteHistory(quotes), txs, LeeReady, 5*time.Second)
+	if aligned[3].Quote != quotes[0] || aligned[5].Quote != quotes[0] {
+		t.Errorf("Align() with delay matched quotes %+v", aligned)
+	}
+	if spread, err := aligned[3].EffectiveSpread(); err != nil || spread != 20 {
+		t.Errorf("EffectiveSpread() = %v, %v, want %v", spread, err, Price(20))
+	}
+	if _, err := aligned[2].EffectiveSpread(); err != ErrNilValue || aligned[2].Matched {
+		t.Errorf("EffectiveSpread() of unmatched trade error = %v, want %v", err, ErrNilValue)
+	}
+}
+
+func TestTradeClassifier_Classify(t *testing.T) {
+	quotes, txs := mockTAQ()
+	c := NewTradeClassifier(LeeReady, 0)
+
+	// Interleave quotes and trades in timestamp order.
+	var got []AlignedTrade
+	next := 0
+	for _, tx := range txs {
+		for next < len(quotes) && !quotes[next].Timestamp.After(tx.Timestamp) {
+			c.AddQuote(quotes[next])
+			next++
+		}
+		got = append(got, c.Classify(tx))
+	}
+	if want := sides(Align(mockQuoteHistory(quotes), txs, LeeReady, 0)); !reflect.DeepEqual(sides(got), want) {
+		t.Errorf("Classify() = %v, want %v", sides(got), want)
+	}
+	if n := c.history.Len("AAPL"); n != 1 {
+		t.Errorf("classifier retained %d quotes, want 1", n)
+	}
+
+	// Quotes of a name that never trades are discarded as well.
+	last := txs[len(txs)-1].Timestamp
+	for i := 0; i < 10; i++ {
+		c.AddQuote(Quote{Name: "MSFT", Timestamp: last.Add(time.Duration(i-5) * time.Second)})
+	}
+	if n := c.history.Len("MSFT"); n != 5 {
+		t.Errorf("classifier retained %d quotes of an untraded name, want 5", n)
+	}
+}
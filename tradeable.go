@@ -21,6 +21,7 @@
 package instruments
 
 import (
+	"math"
 	"time"
 
 	"github.com/pkg/errors"
@@ -28,11 +29,32 @@ import (
 
 var ErrInvalidTx = errors.New("invalid transaction type given")
 
+// Holding is a position in a security, made up of one or more tax lots.
+// A Short holding's lots were opened by sales, and are closed by buying them back.
+//
+// Cost is the cost basis of the remaining volume, including fees paid to acquire it;
+// for a short holding it is the credit received for the remaining volume, net of fees.
+// The metric of the side that opened the position (Buy if long, Sell if short)
+// records the volume-weighted average price of its open lots, and the other side records
+// the volume-weighted average price and date of the position's closing transactions.
+// Method selects which lots are relieved first.
 type Holding struct {
-	Name   string
-	Volume Volume
-	Buy    TxMetric
-	Sell   TxMetric
+	Name      string
+	Volume    Volume
+	Short     bool
+	Buy       TxMetric
+	Sell      TxMetric
+	Cost      Amount
+	Fees      Amount
+	Borrow    Amount
+	Dividends Amount
+	Lots      []Lot
+	Method    LotMethod
+
+	realized Amount
+	invested Amount
+	exited   Volume
+	lotSeq   int
 }
 
 // Buy creates a new Holding from transaction data.
@@ -40,22 +62,189 @@ func Buy(tx Transaction) (*Holding, error) {
 	if !tx.Buy {
 		return nil, errors.Wrap(ErrInvalidTx, "wanted buy, got sell")
 	}
-	return &Holding{
-		Name:   tx.Name,
-		Volume: tx.Volume,
-		Buy:    TxMetric{Price: tx.Price, Date: tx.Timestamp},
-	}, nil
+	if tx.Volume == 0 {
+		return nil, errors.Wrap(ErrInvalidTx, "wanted volume, got 0")
+	}
+	h := &Holding{Name: tx.Name}
+	h.open(tx)
+	return h, nil
 }
 
-// SellOff a number of securities from transaction data.
-func (h *Holding) SellOff(tx Transaction) (*Holding, error) {
-	if tx.Buy || h.Volume < tx.Volume {
+// SellShort creates a new short Holding from transaction data.
+func SellShort(tx Transaction) (*Holding, error) {
+	if tx.Buy {
 		return nil, errors.Wrap(ErrInvalidTx, "wanted sell, got buy")
 	}
-	h.Volume -= tx.Volume
+	if tx.Volume == 0 {
+		return nil, errors.Wrap(ErrInvalidTx, "wanted volume, got 0")
+	}
+	h := &Holding{Name: tx.Name, Short: true}
+	h.open(tx)
 	return h, nil
 }
 
+// Apply a transaction to a holding, routing buys to Add or Cover and sells to SellOff
+// depending on the holding's side.
+func (h *Holding) Apply(tx Transaction) ([]LotRelief, error) {
+	if tx.Buy && h.Short && h.Volume != 0 {
+		return h.Cover(tx)
+	}
+	if tx.Buy {
+		_, err := h.Add(tx)
+		return nil, err
+	}
+	return h.SellOff(tx)
+}
+
+// Add a number of securities to a long holding from transaction data.
+// The transaction opens a new lot, and the holding's Buy price becomes
+// the volume-weighted average of its purchases.
+func (h *Holding) Add(tx Transaction) (*Holding, error) {
+	if err := h.validate(tx, true); err != nil {
+		return nil, err
+	}
+	if h.Short && h.Volume != 0 {
+		return nil, errors.Wrap(ErrInvalidTx, "wanted long holding, got short")
+	}
+	h.ensureLots()
+	h.Short = false
+	h.open(tx)
+	return h, nil
+}
+
+// SellOff a number of securities from transaction data.
+//
+// Selling from a long holding relieves lots by the holding's Method, realizing proceeds
+// net of fees against the cost of each lot relieved. Any volume sold beyond the holding's
+// volume opens a short position. Selling from a short or empty holding adds to the short.
+func (h *Holding) SellOff(tx Transaction) ([]LotRelief, error) {
+	if err := h.validate(tx, false); err != nil {
+		return nil, err
+	}
+	h.ensureLots()
+	if h.Short || h.Volume == 0 {
+		h.Short = true
+		h.open(tx)
+		return nil, nil
+	}
+	if h.Method == SpecificID {
+		return nil, errors.Wrap(ErrInvalidTx, "specific identification requires SellOffLots")
+	}
+	return h.close(tx), nil
+}
+
+// Cover a number of securities sold short from transaction data, relieving lots by the
+// holding's Method. Any volume bought beyond the holding's volume opens a long position.
+func (h *Holding) Cover(tx Transaction) ([]LotRelief, error) {
+	if err := h.validate(tx, true); err != nil {
+		return nil, err
+	}
+	if !h.Short {
+		return nil, errors.Wrap(ErrInvalidTx, "wanted short holding, got long")
+	}
+	if h.Method == SpecificID {
+		return nil, errors.Wrap(ErrInvalidTx, "specific identification requires SellOffLots")
+	}
+	h.ensureLots()
+	return h.close(tx), nil
+}
+
+// SellOffLots closes a number of securities from transaction data,
+// relieving the lots identified by ids in the order given. Each lot may be selected once.
+// The transaction must be a sell for a long holding and a buy for a short holding.
+func (h *Holding) SellOffLots(tx Transaction, ids ...int) ([]LotRelief, error) {
+	if err := h.validate(tx, h.Short); err != nil {
+		return nil, err
+	}
+	h.ensureLots()
+
+	order := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	var available Volume
+	for _, id := range ids {
+		i := h.lotIndex(id)
+		if i < 0 {
+			return nil, errors.Wrapf(ErrLotNotFound, "lot %d", id)
+		}
+		if seen[i] {
+			return nil, errors.Wrapf(ErrInvalidTx, "lot %d selected more than once", id)
+		}
+		seen[i] = true
+		order = append(order, i)
+		available += h.Lots[i].Volume
+	}
+	if available < tx.Volume {
+		return nil, errors.Wrap(ErrInvalidTx, "selected lots hold less than transaction volume")
+	}
+	return h.relieve(tx, order), nil
+}
+
+// validate checks that a transaction is of the wanted side, has volume, and refers to a holding's security.
+func (h *Holding) validate(tx Transaction, buy bool) error {
+	if buy && !tx.Buy {
+		return errors.Wrap(ErrInvalidTx, "wanted buy, got sell")
+	}
+	if !buy && tx.Buy {
+		return errors.Wrap(ErrInvalidTx, "wanted sell, got buy")
+	}
+	if tx.Name != h.Name {
+		return errors.Wrapf(ErrInvalidTx, "wanted %s, got %s", h.Name, tx.Name)
+	}
+	if tx.Volume == 0 {
+		return errors.Wrap(ErrInvalidTx, "wanted volume, got 0")
+	}
+	return nil
+}
+
+// Position returns the signed volume of a holding, negative if it is short.
+func (h *Holding) Position() int64 {
+	if h.Short {
+		return -int64(h.Volume)
+	}
+	return int64(h.Volume)
+}
+
+// AccrueBorrow charges a short holding the cost of borrowing its volume marked at a price,
+// at an annual rate for a period of time. The fee is realized immediately and returned.
+func (h *Holding) AccrueBorrow(mark Price, rate float64, period time.Duration) Amount {
+	if !h.Short || h.Volume == 0 {
+		return 0
+	}
+	fee := Amount(math.Round(float64(NewAmount(mark, h.Volume)) * rate * period.Hours() / (365 * 24)))
+	h.Borrow += fee
+	h.realized -= fee
+	return fee
+}
+
+// Realized returns the gain, or loss if negative, realized by closing volume of a holding,
+// net of fees and borrow costs, along with dividends received or paid.
+func (h *Holding) Realized() Amount {
+	return h.realized
+}
+
+// Unrealized returns the gain, or loss if negative, of a holding's remaining volume
+// marked at a price against its cost basis.
+func (h *Holding) Unrealized(mark Price) Amount {
+	if h.Short {
+		return h.Cost - NewAmount(mark, h.Volume)
+	}
+	return NewAmount(mark, h.Volume) - h.Cost
+}
+
+// TotalReturn returns the sum of realized and unrealized gains of a holding marked at a price.
+func (h *Holding) TotalReturn(mark Price) Amount {
+	return h.Realized() + h.Unrealized(mark)
+}
+
+// ReturnPercent returns the total return of a holding marked at a price
+// as a percentage of the amount invested in it, in hundredths of a percent.
+func (h *Holding) ReturnPercent(mark Price) Amount {
+	if h.invested == 0 {
+		return 0
+	}
+	return roundDiv(h.TotalReturn(mark)*10000, h.invested)
+}
+
 // TxMetric is an associated price-date metric pair.
 type TxMetric struct {
 	Price Price
@@ -65,6 +254,8 @@ type TxMetric struct {
 // ----------------------------------------------------------------------------
 
 // Summary is a metric summary for a particular financial instrument.
+// Each metric is independent, and is replaced rather than mutated as quotes arrive.
+// Windows are fed the midpoint of every quote the summary receives.
 type Summary struct {
 	Name             string
 	N                uint
@@ -73,34 +264,99 @@ type Summary struct {
 	LastBid, LastAsk *SummaryMetric
 	MaxBid, MaxAsk   *SummaryMetric
 	MinBid, MinAsk   *SummaryMetric
+
+	VWAP, TWAP           Price
+	AvgMid, AvgSpread    Price
+	MinSpread, MaxSpread SummaryMetric
+	Windows              []*RollingWindow
+
+	bid, ask, mid, spread RunningStat
+	vwapTotal, vwapVolume Amount
+	twapTotal             float64
+	twapSpan              time.Duration
 }
 
 // NewSummary returns a new summary instance.
-// Summary is constructed from fields supplied by a Holding instance.
+// Summary is constructed from fields supplied by a Holding instance,
+// which seed its metrics until the first quote is received.
 func NewSummary(h Holding) *Summary {
-	metric := &SummaryMetric{Price: h.Buy.Price, Date: h.Buy.Date}
+	seed := SummaryMetric{Price: h.Buy.Price, Date: h.Buy.Date}
+	avgBid, avgAsk := h.Buy.Price, h.Buy.Price
+	lastBid, lastAsk, maxBid, maxAsk, minBid, minAsk := seed, seed, seed, seed, seed, seed
+
 	return &Summary{
-		Name: h.Name, N: 0, Volume: h.Volume, AvgBid: &h.Buy.Price, AvgAsk: &h.Buy.Price,
-		MaxBid: metric, MaxAsk: metric, MinBid: metric, MinAsk: metric, LastAsk: metric, LastBid: metric,
+		Name: h.Name, N: 0, Volume: h.Volume, AvgBid: &avgBid, AvgAsk: &avgAsk,
+		LastBid: &lastBid, LastAsk: &lastAsk, MaxBid: &maxBid, MaxAsk: &maxAsk, MinBid: &minBid, MinAsk: &minAsk,
 	}
 }
 
+// UpdateQuote adds a quote to a summary's statistics, including its volume-weighted average price.
+// VWAP weighs each side's price by its quoted volume. Quotes missing either side are ignored.
+func (s *Summary) UpdateQuote(q *Quote) {
+	if q.Bid.Price == 0 || q.Ask.Price == 0 {
+		return
+	}
+	s.vwapTotal += NewAmount(q.Bid.Price, q.Bid.Volume) + NewAmount(q.Ask.Price, q.Ask.Volume)
+	s.vwapVolume += Amount(q.Bid.Volume) + Amount(q.Ask.Volume)
+	if s.vwapVolume != 0 {
+		s.VWAP = Price(roundDiv(s.vwapTotal, s.vwapVolume))
+	}
+	s.UpdateMetrics(q.Bid.Price, q.Ask.Price, q.Timestamp)
+}
+
+// UpdateMetrics adds a quoted bid and ask to a summary's statistics.
+// TWAP weighs each midpoint by the time it prevailed until the next quote.
+// Quotes missing either side are ignored.
 func (s *Summary) UpdateMetrics(qBid, qAsk Price, t time.Time) {
 	if qBid == 0 || qAsk == 0 {
 		return
 	}
-	s.LastBid = &SummaryMetric{Price: qBid, Date: t}
-	s.LastAsk = &SummaryMetric{Price: qAsk, Date: t}
+	mid := midpoint(qBid, qAsk)
+	if s.mid.N == 0 {
+		s.TWAP = mid
+	} else if span := t.Sub(s.mid.Last.Date); span > 0 {
+		s.twapTotal += float64(s.mid.Last.Price) * span.Seconds()
+		s.twapSpan += span
+		s.TWAP = Price(math.Round(s.twapTotal / s.twapSpan.Seconds()))
+	}
+	s.mid.Add(mid, t)
+	s.spread.Add(qAsk-qBid, t)
+	for _, w := range s.Windows {
+		w.Add(mid, t)
+	}
+	s.AvgMid, s.AvgSpread = s.mid.Mean(), s.spread.Mean()
+	s.MinSpread, s.MaxSpread = s.spread.Min, s.spread.Max
+
+	s.bid.Add(qBid, t)
+	s.ask.Add(qAsk, t)
+	s.N = s.bid.N
+
+	avgBid, avgAsk := s.bid.Mean(), s.ask.Mean()
+	s.AvgBid, s.AvgAsk = &avgBid, &avgAsk
 
-	s.AvgBid.Avg(s.N, qBid)
-	s.AvgAsk.Avg(s.N, qAsk)
-	s.N++
+	lastBid, lastAsk := s.bid.Last, s.ask.Last
+	s.LastBid, s.LastAsk = &lastBid, &lastAsk
 
-	s.MaxAsk.Max(qAsk, t)
-	s.MinAsk.Min(qAsk, t)
+	maxBid, maxAsk := s.bid.Max, s.ask.Max
+	s.MaxBid, s.MaxAsk = &maxBid, &maxAsk
+
+	minBid, minAsk := s.bid.Min, s.ask.Min
+	s.MinBid, s.MinAsk = &minBid, &minAsk
+}
+
+// BidStats returns the running statistics of the bids a summary has received.
+func (s *Summary) BidStats() RunningStat {
+	return s.bid
+}
+
+// AskStats returns the running statistics of the asks a summary has received.
+func (s *Summary) AskStats() RunningStat {
+	return s.ask
+}
 
-	s.MaxBid.Max(qBid, t)
-	s.MinBid.Min(qBid, t)
+// SpreadStats returns the running statistics of the spreads a summary has received.
+func (s *Summary) SpreadStats() RunningStat {
+	return s.spread
 }
 
 // ----------------------------------------------------------------------------
@@ -113,26 +369,28 @@ type SummaryMetric struct {
 
 // Avg is calculated from an old price value,
 // the number of times the average has been calculated, and the new quote price.
+// The price is updated in place with the new average.
 func (p *Price) Avg(n uint, quotePrice Price) Price {
 	numerator := *p*Price(n) + quotePrice
-	newAvg := numerator / (Price(n) + 1)
-	p = &newAvg
+	*p = numerator / (Price(n) + 1)
 
-	return newAvg
+	return *p
 }
 
 // Max is calculated from a SummaryMetric's price field, and a new quoted price.
+// The timestamp is recorded when a new maximum is reached.
 func (s *SummaryMetric) Max(quotePrice Price, timestamp time.Time) Price {
-	if s.Price <= quotePrice {
-		s.Price = quotePrice
+	if s.Price < quotePrice {
+		s.Price, s.Date = quotePrice, timestamp
 	}
 	return s.Price
 }
 
 // Min is calculated from a SummaryMetric's price field, and a new quoted price.
+// The timestamp is recorded when a new minimum is reached.
 func (s *SummaryMetric) Min(quotePrice Price, timestamp time.Time) Price {
-	if s.Price >= quotePrice {
-		s.Price = quotePrice
+	if s.Price > quotePrice {
+		s.Price, s.Date = quotePrice, timestamp
 	}
 	return s.Price
 }
@@ -24,29 +24,36 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 func mockTx(buy bool) Transaction {
 	return Transaction{
-		"Google",
-		buy,
-		QuotedMetric{NewPrice(15.00), NewVolume(20.00)},
-		time.Time{},
+		Name:         "Google",
+		Buy:          buy,
+		QuotedMetric: QuotedMetric{NewPrice(15.00), NewVolume(20.00)},
+		Timestamp:    time.Time{},
 	}
 }
 func mockSellTx() Transaction {
 	return Transaction{
-		"Google",
-		false,
-		QuotedMetric{NewPrice(15.00), NewVolume(10.00)},
-		time.Time{},
+		Name:         "Google",
+		Buy:          false,
+		QuotedMetric: QuotedMetric{NewPrice(15.00), NewVolume(10.00)},
+		Timestamp:    time.Time{},
 	}
 }
 
 func mockHolding() *Holding {
 	return &Holding{
 		Name: "Google", Volume: NewVolume(20.00),
-		Buy: TxMetric{NewPrice(15.00), time.Time{}},
+		Buy:  TxMetric{NewPrice(15.00), time.Time{}},
+		Cost: NewAmount(NewPrice(15.00), NewVolume(20.00)),
+		Lots: []Lot{{1, NewVolume(20.00), NewPrice(15.00), NewAmount(NewPrice(15.00), NewVolume(20.00)), time.Time{}}},
+
+		invested: NewAmount(NewPrice(15.00), NewVolume(20.00)),
+		lotSeq:   1,
 	}
 }
 
@@ -78,6 +85,26 @@ func TestBuy(t *testing.T) {
 	}
 }
 
+func TestHolding_zeroVolume(t *testing.T) {
+	empty := func(buy bool) Transaction { return mockShortTx(buy, 20.00, 0) }
+
+	if _, err := Buy(empty(true)); errors.Cause(err) != ErrInvalidTx {
+		t.Errorf("Buy() error = %v, want %v", err, ErrInvalidTx)
+	}
+	if _, err := SellShort(empty(false)); errors.Cause(err) != ErrInvalidTx {
+		t.Errorf("SellShort() error = %v, want %v", err, ErrInvalidTx)
+	}
+	if _, err := mockHolding().Add(empty(true)); errors.Cause(err) != ErrInvalidTx {
+		t.Errorf("Holding.Add() error = %v, want %v", err, ErrInvalidTx)
+	}
+	if _, err := mockHolding().SellOff(empty(false)); errors.Cause(err) != ErrInvalidTx {
+		t.Errorf("Holding.SellOff() error = %v, want %v", err, ErrInvalidTx)
+	}
+	if _, err := mockHolding().Cover(empty(true)); errors.Cause(err) != ErrInvalidTx {
+		t.Errorf("Holding.Cover() error = %v, want %v", err, ErrInvalidTx)
+	}
+}
+
 func TestPrice_Avg(t *testing.T) {
 	var price = NewPrice(10.00)
 
@@ -99,7 +126,9 @@ func TestPrice_Avg(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.p.Avg(tt.args.n, tt.args.quotePrice)
+			if got := tt.p.Avg(tt.args.n, tt.args.quotePrice); got != tt.want || *tt.p != tt.want {
+				t.Errorf("Price.Avg() = %v (stored %v), want %v", got, *tt.p, tt.want)
+			}
 		})
 	}
 }
@@ -107,6 +136,11 @@ func TestPrice_Avg(t *testing.T) {
 func TestHolding_SellOff(t *testing.T) {
 	wantedHolding := mockHolding()
 	wantedHolding.Volume = NewVolume(10.00)
+	wantedHolding.Cost = NewAmount(NewPrice(15.00), NewVolume(10.00))
+	wantedHolding.Lots[0].Volume = NewVolume(10.00)
+	wantedHolding.Lots[0].Cost = NewAmount(NewPrice(15.00), NewVolume(10.00))
+	wantedHolding.Sell = TxMetric{NewPrice(15.00), time.Time{}}
+	wantedHolding.exited = NewVolume(10.00)
 
 	type args struct {
 		tx Transaction
@@ -115,11 +149,12 @@ func TestHolding_SellOff(t *testing.T) {
 		name    string
 		h       *Holding
 		args    args
-		want    *Holding
+		want    []LotRelief
+		wantH   *Holding
 		wantErr bool
 	}{
-		{"base case", mockHolding(), args{mockSellTx()}, wantedHolding, false},
-		{"wrong Tx type", mockHolding(), args{mockTx(true)}, nil, true},
+		{"base case", mockHolding(), args{mockSellTx()}, []LotRelief{{1, 10, 15000, 15000, time.Time{}, time.Time{}}}, wantedHolding, false},
+		{"wrong Tx type", mockHolding(), args{mockTx(true)}, nil, mockHolding(), true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -131,39 +166,168 @@ func TestHolding_SellOff(t *testing.T) {
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Holding.SellOff() = %v, want %v", got, tt.want)
 			}
+			if !reflect.DeepEqual(tt.h, tt.wantH) {
+				t.Errorf("Holding.SellOff() holding = %v, want %v", tt.h, tt.wantH)
+			}
 		})
 	}
 }
 
-func mockSummary() *Summary {
-	newPrice := NewPrice(10.00)
-	metric := &SummaryMetric{newPrice, time.Time{}}
-	return &Summary{
-		"GOOGL", 0, NewVolume(10.00), &newPrice, &newPrice,
-		metric, metric, metric, metric, metric, metric,
+func mockLotHolding(method LotMethod) *Holding {
+	day := func(d int) time.Time { return time.Date(2017, 1, d, 0, 0, 0, 0, time.UTC) }
+	lot := func(price float64, vol uint32, d int) Transaction {
+		return Transaction{Name: "Google", Buy: true, QuotedMetric: QuotedMetric{NewPrice(price), NewVolume(vol)}, Timestamp: day(d)}
 	}
+	h, _ := Buy(lot(10.00, 10, 1))
+	h.Add(lot(30.00, 10, 2))
+	h.Add(lot(20.00, 10, 3))
+	h.Method = method
+	return h
+}
+
+func TestHolding_SellOff_lots(t *testing.T) {
+	sell := Transaction{Name: "Google", QuotedMetric: QuotedMetric{NewPrice(25.00), NewVolume(15)}}
+
+	tests := []struct {
+		name     string
+		method   LotMethod
+		wantLots []int
+		wantGain Amount
+		wantLeft []int
+	}{
+		{"fifo", FIFO, []int{1, 2}, NewAmount(NewPrice(15.00), 10) - NewAmount(NewPrice(5.00), 5), []int{2, 3}},
+		{"lifo", LIFO, []int{3, 2}, NewAmount(NewPrice(5.00), 10) - NewAmount(NewPrice(5.00), 5), []int{1, 2}},
+		{"hifo", HIFO, []int{2, 3}, -NewAmount(NewPrice(5.00), 10) + NewAmount(NewPrice(5.00), 5), []int{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := mockLotHolding(tt.method)
+			got, err := h.SellOff(sell)
+			if err != nil {
+				t.Fatalf("Holding.SellOff() error = %v", err)
+			}
+			var ids []int
+			var gain Amount
+			for _, r := range got {
+				ids = append(ids, r.Lot)
+				gain += r.Gain()
+			}
+			if !reflect.DeepEqual(ids, tt.wantLots) || gain != tt.wantGain {
+				t.Errorf("Holding.SellOff() lots = %v gain = %v, want %v gain = %v", ids, gain, tt.wantLots, tt.wantGain)
+			}
+			var left []int
+			for _, lot := range h.Lots {
+				left = append(left, lot.ID)
+			}
+			if !reflect.DeepEqual(left, tt.wantLeft) {
+				t.Errorf("Holding.Lots = %v, want %v", left, tt.wantLeft)
+			}
+			if h.Volume != 15 || h.realized != gain {
+				t.Errorf("Holding volume = %v realized = %v, want 15 and %v", h.Volume, h.realized, gain)
+			}
+		})
+	}
+}
+
+func TestHolding_SellOffLots(t *testing.T) {
+	sell := Transaction{Name: "Google", QuotedMetric: QuotedMetric{NewPrice(25.00), NewVolume(15)}}
+
+	h := mockLotHolding(SpecificID)
+	if _, err := h.SellOff(sell); err == nil {
+		t.Errorf("Holding.SellOff() with SpecificID: expected error")
+	}
+	if _, err := h.SellOffLots(sell, 3); err == nil {
+		t.Errorf("Holding.SellOffLots() with too few lots: expected error")
+	}
+	if _, err := h.SellOffLots(sell, 9); errors.Cause(err) != ErrLotNotFound {
+		t.Errorf("Holding.SellOffLots() error = %v, want %v", err, ErrLotNotFound)
+	}
+	if _, err := h.SellOffLots(sell, 1, 1); errors.Cause(err) != ErrInvalidTx {
+		t.Errorf("Holding.SellOffLots() with repeated lot error = %v, want %v", err, ErrInvalidTx)
+	}
+
+	got, err := h.SellOffLots(sell, 3, 1)
+	if err != nil {
+		t.Fatalf("Holding.SellOffLots() error = %v", err)
+	}
+	want := []LotRelief{
+		{3, 10, NewAmount(NewPrice(20.00), 10), NewAmount(NewPrice(25.00), 10), time.Date(2017, 1, 3, 0, 0, 0, 0, time.UTC), time.Time{}},
+		{1, 5, NewAmount(NewPrice(10.00), 5), NewAmount(NewPrice(25.00), 5), time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Holding.SellOffLots() = %v, want %v", got, want)
+	}
+	if h.Buy.Price != NewPrice(23.33) {
+		t.Errorf("Holding.Buy.Price = %v, want %v", h.Buy.Price, NewPrice(23.33))
+	}
+}
+
+func mockSummary() *Summary {
+	return NewSummary(Holding{Name: "GOOGL", Volume: NewVolume(10.00), Buy: TxMetric{NewPrice(10.00), time.Time{}}})
 }
 func TestSummary_UpdateMetrics(t *testing.T) {
-	type args struct {
+	at := func(s int) time.Time { return time.Date(2017, 1, 3, 9, 30, s, 0, time.UTC) }
+
+	type quote struct {
 		qBid Price
 		qAsk Price
 		t    time.Time
 	}
+	type want struct {
+		n                uint
+		avgBid, avgAsk   Price
+		lastBid, lastAsk SummaryMetric
+		maxBid, maxAsk   SummaryMetric
+		minBid, minAsk   SummaryMetric
+	}
+	seed := SummaryMetric{NewPrice(10.00), time.Time{}}
 	tests := []struct {
-		name string
-		s    *Summary
-		args args
+		name   string
+		quotes []quote
+		want   want
 	}{
-		{"Base case", mockSummary(), args{NewPrice(10.00), NewPrice(10.00), time.Time{}}},
-		{"zero case", mockSummary(), args{NewPrice(0), NewPrice(10.00), time.Time{}}},
+		{"no quotes", nil, want{0, NewPrice(10.00), NewPrice(10.00), seed, seed, seed, seed, seed, seed}},
+		{"zero case", []quote{{NewPrice(0), NewPrice(10.00), at(0)}},
+			want{0, NewPrice(10.00), NewPrice(10.00), seed, seed, seed, seed, seed, seed}},
+		{"single quote", []quote{{NewPrice(20.00), NewPrice(20.10), at(1)}},
+			want{1, NewPrice(20.00), NewPrice(20.10),
+				SummaryMetric{NewPrice(20.00), at(1)}, SummaryMetric{NewPrice(20.10), at(1)},
+				SummaryMetric{NewPrice(20.00), at(1)}, SummaryMetric{NewPrice(20.10), at(1)},
+				SummaryMetric{NewPrice(20.00), at(1)}, SummaryMetric{NewPrice(20.10), at(1)}}},
+		{"many quotes", []quote{
+			{NewPrice(20.00), NewPrice(20.10), at(1)},
+			{NewPrice(21.00), NewPrice(21.20), at(2)},
+			{NewPrice(0), NewPrice(30.00), at(3)},
+			{NewPrice(19.01), NewPrice(19.05), at(4)},
+		}, want{3, NewPrice(20.00), NewPrice(20.12),
+			SummaryMetric{NewPrice(19.01), at(4)}, SummaryMetric{NewPrice(19.05), at(4)},
+			SummaryMetric{NewPrice(21.00), at(2)}, SummaryMetric{NewPrice(21.20), at(2)},
+			SummaryMetric{NewPrice(19.01), at(4)}, SummaryMetric{NewPrice(19.05), at(4)}}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.s.UpdateMetrics(tt.args.qBid, tt.args.qAsk, tt.args.t)
+			s := mockSummary()
+			for _, q := range tt.quotes {
+				s.UpdateMetrics(q.qBid, q.qAsk, q.t)
+			}
+			got := want{s.N, *s.AvgBid, *s.AvgAsk, *s.LastBid, *s.LastAsk, *s.MaxBid, *s.MaxAsk, *s.MinBid, *s.MinAsk}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Summary.UpdateMetrics() = %+v, want %+v", got, tt.want)
+			}
 		})
 	}
 }
 
+func TestSummary_independentMetrics(t *testing.T) {
+	s := mockSummary()
+	s.MaxAsk.Max(NewPrice(50.00), time.Time{})
+	*s.AvgBid = NewPrice(1.00)
+
+	if s.MinBid.Price != NewPrice(10.00) || s.LastBid.Price != NewPrice(10.00) || *s.AvgAsk != NewPrice(10.00) {
+		t.Errorf("NewSummary() metrics share state: %+v", s)
+	}
+}
+
 func mockSummaryMetric() *SummaryMetric {
 	return &SummaryMetric{Price: NewPrice(10.00), Date: time.Time{}}
 }
@@ -226,7 +390,15 @@ func TestNewSummary(t *testing.T) {
 		args args
 		want *Summary
 	}{
-		// TODO: Add test cases.
+		{"base case", args{*mockHolding()}, func() *Summary {
+			price := NewPrice(15.00)
+			metric := func() *SummaryMetric { return &SummaryMetric{price, time.Time{}} }
+			avgBid, avgAsk := price, price
+			return &Summary{
+				Name: "Google", Volume: NewVolume(20.00), AvgBid: &avgBid, AvgAsk: &avgAsk,
+				LastBid: metric(), LastAsk: metric(), MaxBid: metric(), MaxAsk: metric(), MinBid: metric(), MinAsk: metric(),
+			}
+		}()},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -236,3 +408,269 @@ func TestNewSummary(t *testing.T) {
 		})
 	}
 }
+
+func TestHolding_SellOff_fees(t *testing.T) {
+	buy := mockTx(true)
+	buy.Fee = 200
+	h, err := Buy(buy)
+	if err != nil {
+		t.Fatalf("Buy() error = %v", err)
+	}
+	if want := NewAmount(NewPrice(15.00), NewVolume(20)) + 200; h.Cost != want {
+		t.Errorf("Holding.Cost = %v, want %v", h.Cost, want)
+	}
+
+	sell := mockSellTx()
+	sell.Price = NewPrice(20.00)
+	sell.Fee = 100
+	if _, err = h.SellOff(sell); err != nil {
+		t.Fatalf("Holding.SellOff() error = %v", err)
+	}
+	if want := NewAmount(NewPrice(15.00), NewVolume(10)) + 100; h.Cost != want {
+		t.Errorf("Holding.Cost = %v, want %v", h.Cost, want)
+	}
+	if want := NewAmount(NewPrice(5.00), NewVolume(10)) - 100 - 100; h.realized != want {
+		t.Errorf("Holding.realized = %v, want %v", h.realized, want)
+	}
+	if want := Amount(300); h.Fees != want {
+		t.Errorf("Holding.Fees = %v, want %v", h.Fees, want)
+	}
+}
+
+func TestHolding_Add(t *testing.T) {
+	addTx := mockTx(true)
+	addTx.Price = NewPrice(18.00)
+	addTx.Volume = NewVolume(10)
+	addTx.Fee = 100
+
+	wantedHolding := mockHolding()
+	wantedHolding.Volume = NewVolume(30)
+	wantedHolding.Buy.Price = NewPrice(16.00)
+	wantedHolding.Cost = NewAmount(NewPrice(15.00), NewVolume(20)) + NewAmount(NewPrice(18.00), NewVolume(10)) + 100
+	wantedHolding.Fees = 100
+	wantedHolding.Lots = append(wantedHolding.Lots, Lot{2, NewVolume(10), NewPrice(18.00), NewAmount(NewPrice(18.00), NewVolume(10)) + 100, time.Time{}})
+	wantedHolding.invested += NewAmount(NewPrice(18.00), NewVolume(10)) + 100
+	wantedHolding.lotSeq = 2
+
+	otherTx := mockTx(true)
+	otherTx.Name = "Apple"
+
+	type args struct {
+		tx Transaction
+	}
+	tests := []struct {
+		name    string
+		h       *Holding
+		args    args
+		want    *Holding
+		wantErr bool
+	}{
+		{"base case", mockHolding(), args{addTx}, wantedHolding, false},
+		{"wrong Tx type", mockHolding(), args{mockSellTx()}, nil, true},
+		{"wrong name", mockHolding(), args{otherTx}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.h.Add(tt.args.tx)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Holding.Add() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && errors.Cause(err) != ErrInvalidTx {
+				t.Errorf("Holding.Add() error = %v, want %v", err, ErrInvalidTx)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Holding.Add() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHolding_PnL(t *testing.T) {
+	h := mockLotHolding(FIFO)
+	sell := Transaction{Name: "Google", QuotedMetric: QuotedMetric{NewPrice(25.00), NewVolume(10)}, Fee: 100}
+	if _, err := h.SellOff(sell); err != nil {
+		t.Fatalf("Holding.SellOff() error = %v", err)
+	}
+	sell.Price, sell.Volume, sell.Fee = NewPrice(35.00), NewVolume(10), 0
+	if _, err := h.SellOff(sell); err != nil {
+		t.Fatalf("Holding.SellOff() error = %v", err)
+	}
+	if want := (TxMetric{NewPrice(30.00), time.Time{}}); h.Sell != want {
+		t.Errorf("Holding.Sell = %v, want %v", h.Sell, want)
+	}
+
+	tests := []struct {
+		name string
+		got  Amount
+		want Amount
+	}{
+		{"realized", h.Realized(), NewAmount(NewPrice(15.00), 10) - 100 + NewAmount(NewPrice(5.00), 10)},
+		{"unrealized gain", h.Unrealized(NewPrice(22.00)), NewAmount(NewPrice(2.00), 10)},
+		{"unrealized loss", h.Unrealized(NewPrice(17.00)), -NewAmount(NewPrice(3.00), 10)},
+		{"total return", h.TotalReturn(NewPrice(22.00)), NewAmount(NewPrice(22.00), 10) - 100},
+		{"return percent", h.ReturnPercent(NewPrice(22.00)), 3650},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("Holding %s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+	if got, want := h.ReturnPercent(NewPrice(22.00)).ToPercent(), "36.50%"; got != want {
+		t.Errorf("Amount.ToPercent() = %v, want %v", got, want)
+	}
+}
+
+func mockShortTx(buy bool, price float64, vol uint32) Transaction {
+	return Transaction{Name: "Google", Buy: buy, QuotedMetric: QuotedMetric{NewPrice(price), NewVolume(vol)}}
+}
+
+func TestSellShort(t *testing.T) {
+	h, err := SellShort(mockShortTx(false, 20.00, 10))
+	if err != nil {
+		t.Fatalf("SellShort() error = %v", err)
+	}
+	if h.Position() != -10 || !h.Short || h.Cost != NewAmount(NewPrice(20.00), 10) || h.Sell.Price != NewPrice(20.00) {
+		t.Errorf("SellShort() = %v", h)
+	}
+	if _, err = SellShort(mockShortTx(true, 20.00, 10)); err == nil {
+		t.Errorf("SellShort() with buy: expected error")
+	}
+}
+
+func TestHolding_Apply(t *testing.T) {
+	type step struct {
+		tx           Transaction
+		wantPosition int64
+		wantRealized Amount
+		wantCost     Amount
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"long flips short", []step{
+			{mockShortTx(true, 10.00, 10), 10, 0, NewAmount(NewPrice(10.00), 10)},
+			{mockShortTx(false, 12.00, 15), -5, NewAmount(NewPrice(2.00), 10), NewAmount(NewPrice(12.00), 5)},
+			{mockShortTx(false, 14.00, 5), -10, NewAmount(NewPrice(2.00), 10), NewAmount(NewPrice(12.00), 5) + NewAmount(NewPrice(14.00), 5)},
+		}},
+		{"short covers and flips long", []step{
+			{mockShortTx(false, 20.00, 10), -10, 0, NewAmount(NewPrice(20.00), 10)},
+			{mockShortTx(true, 15.00, 4), -6, NewAmount(NewPrice(5.00), 4), NewAmount(NewPrice(20.00), 6)},
+			{mockShortTx(true, 22.00, 8), 2, NewAmount(NewPrice(5.00), 4) - NewAmount(NewPrice(2.00), 6), NewAmount(NewPrice(22.00), 2)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Holding{Name: "Google"}
+			for i, s := range tt.steps {
+				if _, err := h.Apply(s.tx); err != nil {
+					t.Fatalf("step %d: Holding.Apply() error = %v", i, err)
+				}
+				if h.Position() != s.wantPosition || h.Realized() != s.wantRealized || h.Cost != s.wantCost {
+					t.Errorf("step %d: position = %v realized = %v cost = %v, want %v, %v, %v",
+						i, h.Position(), h.Realized(), h.Cost, s.wantPosition, s.wantRealized, s.wantCost)
+				}
+			}
+		})
+	}
+}
+
+func TestHolding_Cover(t *testing.T) {
+	h, _ := SellShort(mockShortTx(false, 20.00, 10))
+	cover := mockShortTx(true, 18.00, 10)
+	cover.Fee = 100
+
+	got, err := h.Cover(cover)
+	if err != nil {
+		t.Fatalf("Holding.Cover() error = %v", err)
+	}
+	want := []LotRelief{{1, 10, NewAmount(NewPrice(18.00), 10) + 100, NewAmount(NewPrice(20.00), 10), time.Time{}, time.Time{}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Holding.Cover() = %v, want %v", got, want)
+	}
+	if h.Volume != 0 || h.Realized() != NewAmount(NewPrice(2.00), 10)-100 || h.Buy.Price != NewPrice(18.00) {
+		t.Errorf("Holding.Cover() holding = %v", h)
+	}
+
+	long := mockHolding()
+	if _, err = long.Cover(cover); err == nil {
+		t.Errorf("Holding.Cover() on long holding: expected error")
+	}
+	if _, err = h.Add(mockShortTx(true, 18.00, 10)); err != nil {
+		t.Errorf("Holding.Add() on flat holding error = %v", err)
+	}
+}
+
+func TestHolding_Unrealized_short(t *testing.T) {
+	h, _ := SellShort(mockShortTx(false, 20.00, 10))
+
+	tests := []struct {
+		name string
+		mark Price
+		want Amount
+	}{
+		{"gain", NewPrice(15.00), NewAmount(NewPrice(5.00), 10)},
+		{"loss", NewPrice(25.00), -NewAmount(NewPrice(5.00), 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.Unrealized(tt.mark); got != tt.want {
+				t.Errorf("Holding.Unrealized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHolding_AccrueBorrow(t *testing.T) {
+	h, _ := SellShort(mockShortTx(false, 100.00, 365))
+	if got, want := h.AccrueBorrow(NewPrice(100.00), 0.01, 24*time.Hour), NewAmount(NewPrice(1.00), 1); got != want {
+		t.Errorf("Holding.AccrueBorrow() = %v, want %v", got, want)
+	}
+	if h.Borrow != 100 || h.Realized() != -100 {
+		t.Errorf("Holding borrow = %v realized = %v, want 100 and -100", h.Borrow, h.Realized())
+	}
+	if got := mockHolding().AccrueBorrow(NewPrice(100.00), 0.01, 24*time.Hour); got != 0 {
+		t.Errorf("Holding.AccrueBorrow() on long holding = %v, want 0", got)
+	}
+}
+
+func TestSummary_UpdateQuote(t *testing.T) {
+	at := func(s int) time.Time { return time.Date(2017, 1, 3, 9, 30, s, 0, time.UTC) }
+	quote := func(bid, ask Price, bidVol, askVol uint32, s int) *Quote {
+		return &Quote{Name: "GOOGL", Bid: QuotedMetric{bid, NewVolume(bidVol)}, Ask: QuotedMetric{ask, NewVolume(askVol)}, Timestamp: at(s)}
+	}
+
+	s := mockSummary()
+	s.UpdateQuote(quote(1000, 1020, 100, 100, 0))
+	if s.TWAP != NewPrice(10.10) || s.VWAP != NewPrice(10.10) {
+		t.Errorf("Summary first quote TWAP = %v VWAP = %v, want $10.10", s.TWAP, s.VWAP)
+	}
+	s.UpdateQuote(quote(1010, 1030, 300, 100, 10))
+	s.UpdateQuote(&Quote{Name: "GOOGL", Ask: NewQuotedMetric(11.00, 100), Timestamp: at(20)})
+	s.UpdateQuote(quote(1000, 1040, 100, 100, 40))
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"N", s.N, uint(3)},
+		{"VWAP", s.VWAP, NewPrice(10.15)},
+		{"TWAP", s.TWAP, NewPrice(10.18)},
+		{"AvgMid", s.AvgMid, NewPrice(10.17)},
+		{"AvgSpread", s.AvgSpread, NewPrice(0.27)},
+		{"MinSpread", s.MinSpread, SummaryMetric{NewPrice(0.20), at(0)}},
+		{"MaxSpread", s.MaxSpread, SummaryMetric{NewPrice(0.40), at(40)}},
+		{"AvgBid", *s.AvgBid, Price(1003)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("Summary.%s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"math"
+	"time"
+)
+
+// Reason is the code a Validator gives for accepting or rejecting a quote.
+type Reason int
+
+const (
+	// Valid indicates that a quote passed every rule.
+	Valid Reason = iota // 0
+	// EmptySide indicates that a quote's bid or ask has no price.
+	EmptySide
+	// NegativePrice indicates that a quote's bid or ask has a negative price.
+	NegativePrice
+	// Crossed indicates that a quote's bid is above its ask.
+	Crossed
+	// Locked indicates that a quote's bid equals its ask.
+	Locked
+	// Spike indicates that a quote's midpoint is an outlier against its recent history.
+	Spike
+	// Stale indicates that a quote is too far behind the latest quote of its stream.
+	Stale
+	// OutOfOrder indicates that a quote is older than the previous quote of its name.
+	OutOfOrder
+)
+
+var reasonNames = [...]string{"valid", "empty side", "negative price", "crossed", "locked", "spike", "stale", "out of order"}
+
+func (r Reason) String() string {
+	if r < 0 || int(r) >= len(reasonNames) {
+		return "unknown"
+	}
+	return reasonNames[r]
+}
+
+// Rule is a check applied to quotes by a Validator.
+// Stateful rules learn only from quotes that pass every rule, through Accept.
+type Rule interface {
+	Check(q *Quote) Reason
+	Accept(q *Quote)
+}
+
+// ----------------------------------------------------------------------------
+
+// PriceRule rejects quotes with a missing or negative price on either side.
+type PriceRule struct{}
+
+// Check a quote's prices.
+func (PriceRule) Check(q *Quote) Reason {
+	switch {
+	case q.Bid.Price < 0 || q.Ask.Price < 0:
+		return NegativePrice
+	case q.Bid.Price == 0 || q.Ask.Price == 0:
+		return EmptySide
+	}
+	return Valid
+}
+
+// Accept is a no-op; a PriceRule is stateless.
+func (PriceRule) Accept(*Quote) {}
+
+// CrossedRule rejects quotes whose bid is above their ask, and unless AllowLocked is set,
+// quotes whose bid equals their ask.
+type CrossedRule struct {
+	AllowLocked bool
+}
+
+// Check a quote for a crossed or locked market.
+func (r CrossedRule) Check(q *Quote) Reason {
+	switch {
+	case q.Bid.Price > q.Ask.Price:
+		return Crossed
+	case q.Bid.Price == q.Ask.Price && !r.AllowLocked:
+		return Locked
+	}
+	return Valid
+}
+
+// Accept is a no-op; a CrossedRule is stateless.
+func (CrossedRule) Accept(*Quote) {}
+
+// SpikeRule rejects quotes whose midpoint lies more than Threshold standard deviations
+// from the mean of the last Size accepted midpoints of their name. No quote is rejected
+// until MinObs midpoints have been accepted, or while they show no variation.
+//
+// After Reanchor consecutive spikes of a name, or Size if Reanchor is zero, its price is
+// taken to have shifted: the last spike is accepted and the window restarts from it.
+type SpikeRule struct {
+	Size      int
+	Threshold float64
+	MinObs    int
+	Reanchor  int
+
+	windows map[string]*RollingWindow
+	spikes  map[string]int
+}
+
+// NewSpikeRule returns a new spike rule over a window of midpoints, with a z-score threshold.
+func NewSpikeRule(size int, threshold float64) *SpikeRule {
+	return &SpikeRule{Size: size, Threshold: threshold, MinObs: size}
+}
+
+// Check the z-score of a quote's midpoint, counting consecutive spikes of its name.
+func (r *SpikeRule) Check(q *Quote) Reason {
+	w, ok := r.windows[q.Name]
+	if !ok || w.Len() < r.MinObs {
+		return Valid
+	}
+	sd := math.Sqrt(w.Variance())
+	if sd == 0 {
+		return Valid
+	}
+	if z := math.Abs(float64(midpoint(q.Bid.Price, q.Ask.Price)-w.Mean())) / sd; z <= r.Threshold {
+		return Valid
+	}
+	if r.spikes == nil {
+		r.spikes = make(map[string]int)
+	}
+	reanchor := r.Reanchor
+	if reanchor == 0 {
+		reanchor = r.Size
+	}
+	if r.spikes[q.Name]++; r.spikes[q.Name] < reanchor {
+		return Spike
+	}
+	delete(r.windows, q.Name)
+	return Valid
+}
+
+// Accept adds a quote's midpoint to the window of its name, ending any run of spikes.
+func (r *SpikeRule) Accept(q *Quote) {
+	delete(r.spikes, q.Name)
+	if r.windows == nil {
+		r.windows = make(map[string]*RollingWindow)
+	}
+	w, ok := r.windows[q.Name]
+	if !ok {
+		w = NewCountWindow(r.Size)
+		r.windows[q.Name] = w
+	}
+	w.Add(midpoint(q.Bid.Price, q.Ask.Price), q.Timestamp)
+}
+
+// StaleRule rejects quotes timestamped more than MaxAge before the latest accepted quote
+// of any name, such as quotes delivered late by a slow source.
+type StaleRule struct {
+	MaxAge time.Duration
+
+	latest time.Time
+}
+
+// NewStaleRule returns a new staleness rule with a maximum age.
+func NewStaleRule(maxAge time.Duration) *StaleRule {
+	return &StaleRule{MaxAge: maxAge}
+}
+
+// Check the age of a quote against the stream's latest.
+func (r *StaleRule) Check(q *Quote) Reason {
+	if r.latest.Sub(q.Timestamp) > r.MaxAge {
+		return Stale
+	}
+	return Valid
+}
+
+// Accept advances the stream's latest timestamp.
+func (r *StaleRule) Accept(q *Quote) {
+	if q.Timestamp.After(r.latest) {
+		r.latest = q.Timestamp
+	}
+}
+
+// MonotonicRule rejects quotes timestamped before the previous accepted quote of their name.
+type MonotonicRule struct {
+	last map[string]time.Time
+}
+
+// NewMonotonicRule returns a new monotonic timestamp rule.
+func NewMonotonicRule() *MonotonicRule {
+	return &MonotonicRule{}
+}
+
+// Check a quote's timestamp against the previous of its name.
+func (r *MonotonicRule) Check(q *Quote) Reason {
+	if q.Timestamp.Before(r.last[q.Name]) {
+		return OutOfOrder
+	}
+	return Valid
+}
+
+// Accept records a quote's timestamp as the latest of its name.
+func (r *MonotonicRule) Accept(q *Quote) {
+	if r.last == nil {
+		r.last = make(map[string]time.Time)
+	}
+	r.last[q.Name] = q.Timestamp
+}
+
+// ----------------------------------------------------------------------------
+
+// Validator applies a pipeline of rules to quotes, in order, rejecting a quote with the
+// reason of the first rule it fails. OnReject, if set, is called with each rejected quote.
+type Validator struct {
+	Rules    []Rule
+	OnReject func(q Quote, r Reason)
+
+	accepted   int
+	rejections map[Reason]int
+}
+
+// NewValidator returns a new validator applying rules in order.
+func NewValidator(rules ...Rule) *Validator {
+	return &Validator{Rules: rules}
+}
+
+// DefaultRules returns a pipeline rejecting empty, negative, crossed and locked quotes,
+// quotes out of timestamp order, and midpoints more than 5 standard deviations from the
+// last 20 of their name, unless 20 in a row show that its price has shifted.
+func DefaultRules() []Rule {
+	return []Rule{PriceRule{}, CrossedRule{}, NewMonotonicRule(), NewSpikeRule(20, 5)}
+}
+
+// Validate a quote, returning the reason it was rejected, or Valid.
+func (v *Validator) Validate(q *Quote) Reason {
+	for _, rule := range v.Rules {
+		if r := rule.Check(q); r != Valid {
+			if v.rejections == nil {
+				v.rejections = make(map[Reason]int)
+			}
+			v.rejections[r]++
+			if v.OnReject != nil {
+				v.OnReject(*q, r)
+			}
+			return r
+		}
+	}
+	for _, rule := range v.Rules {
+		rule.Accept(q)
+	}
+	v.accepted++
+	return Valid
+}
+
+// Accepted returns the number of quotes a validator has accepted.
+func (v *Validator) Accepted() int {
+	return v.accepted
+}
+
+// Rejections returns the number of quotes a validator has rejected, by reason.
+func (v *Validator) Rejections() map[Reason]int {
+	counts := make(map[Reason]int, len(v.rejections))
+	for r, n := range v.rejections {
+		counts[r] = n
+	}
+	return counts
+}
+
+// Filter returns an iterator over the quotes of another that pass a validator.
+func (v *Validator) Filter(it QuoteIterator) QuoteIterator {
+	return &validIterator{source: it, v: v}
+}
+
+// validIterator skips the quotes of its source that fail validation.
+type validIterator struct {
+	source QuoteIterator
+	v      *Validator
+}
+
+func (it *validIterator) Next() (Quote, error) {
+	for {
+		q, err := it.source.Next()
+		if err != nil {
+			return q, err
+		}
+		if it.v.Validate(&q) == Valid {
+			return q, nil
+		}
+	}
+}
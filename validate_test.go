@@ -0,0 +1,182 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func mockValidQuote(bid, ask Price, sec int) Quote {
+	return Quote{
+		Name:      "AAPL",
+		Bid:       QuotedMetric{bid, 100},
+		Ask:       QuotedMetric{ask, 100},
+		Timestamp: time.Date(2017, 1, 3, 9, 30, sec, 0, time.UTC),
+	}
+}
+
+func TestRules_Check(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		q    Quote
+		want Reason
+	}{
+		{"price valid", PriceRule{}, mockValidQuote(9900, 10000, 0), Valid},
+		{"price empty bid", PriceRule{}, mockValidQuote(0, 10000, 0), EmptySide},
+		{"price negative ask", PriceRule{}, mockValidQuote(9900, -10000, 0), NegativePrice},
+		{"crossed", CrossedRule{}, mockValidQuote(10100, 10000, 0), Crossed},
+		{"locked", CrossedRule{}, mockValidQuote(10000, 10000, 0), Locked},
+		{"locked allowed", CrossedRule{AllowLocked: true}, mockValidQuote(10000, 10000, 0), Valid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Check(&tt.q); got != tt.want {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatefulRules(t *testing.T) {
+	monotonic := NewMonotonicRule()
+	first := mockValidQuote(9900, 10000, 10)
+	monotonic.Accept(&first)
+	earlier, other := mockValidQuote(9900, 10000, 5), mockValidQuote(9900, 10000, 5)
+	other.Name = "MSFT"
+	if got := monotonic.Check(&earlier); got != OutOfOrder {
+		t.Errorf("MonotonicRule.Check() = %v, want %v", got, OutOfOrder)
+	}
+	if got := monotonic.Check(&other); got != Valid {
+		t.Errorf("MonotonicRule.Check() of another name = %v, want %v", got, Valid)
+	}
+
+	stale := NewStaleRule(3 * time.Second)
+	stale.Accept(&first)
+	if got := stale.Check(&other); got != Stale {
+		t.Errorf("StaleRule.Check() = %v, want %v", got, Stale)
+	}
+	recent := mockValidQuote(9900, 10000, 8)
+	if got := stale.Check(&recent); got != Valid {
+		t.Errorf("StaleRule.Check() of recent quote = %v, want %v", got, Valid)
+	}
+}
+
+func TestValidator_Validate(t *testing.T) {
+	var rejected []Reason
+	v := NewValidator(DefaultRules()...)
+	v.OnReject = func(_ Quote, r Reason) { rejected = append(rejected, r) }
+
+	mids := []Price{10000, 10010, 9990, 10005, 9995, 10000, 10010, 9990, 10005, 9995,
+		10000, 10010, 9990, 10005, 9995, 10000, 10010, 9990, 10005, 9995}
+	for i, mid := range mids {
+		q := mockValidQuote(mid-5, mid+5, i)
+		if got := v.Validate(&q); got != Valid {
+			t.Fatalf("Validate() of quote %d = %v, want %v", i, got, Valid)
+		}
+	}
+
+	quotes := []Quote{
+		mockValidQuote(10500, 10510, 21), // spike
+		mockValidQuote(9995, 10005, 22),
+		mockValidQuote(0, 10005, 23),
+		mockValidQuote(10010, 10005, 24),
+		mockValidQuote(9995, 10005, 20),  // earlier than the last accepted
+		mockValidQuote(10500, 10510, 25), // still a spike: the first was never learned
+	}
+	var got []Reason
+	for i := range quotes {
+		got = append(got, v.Validate(&quotes[i]))
+	}
+	want := []Reason{Spike, Valid, EmptySide, Crossed, OutOfOrder, Spike}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(rejected, []Reason{Spike, EmptySide, Crossed, OutOfOrder, Spike}) {
+		t.Errorf("OnReject() called with %v", rejected)
+	}
+
+	counts := map[Reason]int{Spike: 2, EmptySide: 1, Crossed: 1, OutOfOrder: 1}
+	if !reflect.DeepEqual(v.Rejections(), counts) || v.Accepted() != 21 {
+		t.Errorf("Rejections() = %v, Accepted() = %d, want %v and 21", v.Rejections(), v.Accepted(), counts)
+	}
+}
+
+func TestValidator_levelShift(t *testing.T) {
+	v := NewValidator(DefaultRules()...)
+	for i := 0; i < 20; i++ {
+		mid := Price(10000 + 10*(i%3))
+		q := mockValidQuote(mid-5, mid+5, i)
+		v.Validate(&q)
+	}
+
+	// A sustained jump is rejected until it has persisted for the window's size.
+	var rejected int
+	for i := 0; i < 100; i++ {
+		mid := Price(11000 + 10*(i%3))
+		q := mockValidQuote(mid-5, mid+5, 20+i)
+		if got := v.Validate(&q); got == Spike {
+			rejected++
+		} else if got != Valid {
+			t.Fatalf("Validate() of quote %d = %v", i, got)
+		}
+	}
+	if rejected != 19 || v.Accepted() != 101 {
+		t.Errorf("Validate() rejected %d and accepted %d, want 19 and 101", rejected, v.Accepted())
+	}
+}
+
+func TestValidator_zeroValue(t *testing.T) {
+	v := &Validator{Rules: []Rule{&MonotonicRule{}, &SpikeRule{Size: 20, Threshold: 5}}}
+	quotes := []Quote{mockValidQuote(9900, 10000, 1), mockValidQuote(9910, 10010, 2), mockValidQuote(9900, 10000, 0)}
+	var got []Reason
+	for i := range quotes {
+		got = append(got, v.Validate(&quotes[i]))
+	}
+	if want := []Reason{Valid, Valid, OutOfOrder}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() = %v, want %v", got, want)
+	}
+	if counts := map[Reason]int{OutOfOrder: 1}; !reflect.DeepEqual(v.Rejections(), counts) {
+		t.Errorf("Rejections() = %v, want %v", v.Rejections(), counts)
+	}
+}
+
+func TestValidator_Filter(t *testing.T) {
+	quotes := []Quote{mockValidQuote(9900, 10000, 0), mockValidQuote(10000, 9900, 1), mockValidQuote(9910, 10010, 2)}
+	v := NewValidator(PriceRule{}, CrossedRule{})
+
+	var got []int
+	err := Drain(v.Filter(NewSliceIterator(quotes)), func(q Quote) error {
+		got = append(got, q.Timestamp.Second())
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(got, []int{0, 2}) {
+		t.Errorf("Filter() yielded quotes at %v, %v, want [0 2]", got, err)
+	}
+}
+
+func TestReason_String(t *testing.T) {
+	if Crossed.String() != "crossed" || Reason(99).String() != "unknown" {
+		t.Errorf("String() = %q, %q", Crossed, Reason(99))
+	}
+}
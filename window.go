@@ -0,0 +1,216 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"math"
+	"time"
+)
+
+// RollingWindow maintains statistics over the most recent observations of a price series,
+// bounded by a number of observations, by their age, or both. Every statistic is updated
+// in amortized constant time as observations are added and evicted.
+type RollingWindow struct {
+	Size   int
+	Period time.Duration
+
+	obs        []windowObs
+	head, seq  int
+	sum        Amount
+	sumSq      float64
+	sumRetSq   float64
+	maxq, minq deque
+	ema        float64
+	last       windowObs
+}
+
+// windowObs is a price observed at a time, with the log return from the observation before it.
+type windowObs struct {
+	seq   int
+	price Price
+	t     time.Time
+	ret   float64
+}
+
+// NewCountWindow returns a rolling window over the last size observations.
+func NewCountWindow(size int) *RollingWindow {
+	return &RollingWindow{Size: size}
+}
+
+// NewTimeWindow returns a rolling window over observations made within a period of the latest.
+func NewTimeWindow(period time.Duration) *RollingWindow {
+	return &RollingWindow{Period: period}
+}
+
+// Add a price observed at a time to a rolling window, evicting observations that fall out of it.
+// Observations are expected in timestamp order.
+func (w *RollingWindow) Add(p Price, t time.Time) {
+	o := windowObs{seq: w.seq, price: p, t: t}
+	if w.seq > 0 && w.last.price > 0 && p > 0 {
+		o.ret = math.Log(float64(p) / float64(w.last.price))
+	}
+	w.updateEMA(o)
+	w.seq++
+	w.last = o
+
+	w.obs = append(w.obs, o)
+	w.sum += Amount(p)
+	w.sumSq += float64(p) * float64(p)
+	w.sumRetSq += o.ret * o.ret
+	w.maxq.push(o, func(back windowObs) bool { return back.price <= p })
+	w.minq.push(o, func(back windowObs) bool { return back.price >= p })
+
+	for w.Len() > 0 && w.expired(w.obs[w.head], o) {
+		w.evict()
+	}
+}
+
+// expired reports whether an observation has fallen out of a window given the latest one.
+func (w *RollingWindow) expired(o, latest windowObs) bool {
+	if w.Size > 0 && w.Len() > w.Size {
+		return true
+	}
+	return w.Period > 0 && latest.t.Sub(o.t) >= w.Period
+}
+
+// evict removes the oldest observation from a window.
+func (w *RollingWindow) evict() {
+	o := w.obs[w.head]
+	w.head++
+	w.sum -= Amount(o.price)
+	w.sumSq -= float64(o.price) * float64(o.price)
+	w.sumRetSq -= o.ret * o.ret
+	w.maxq.evict(o.seq)
+	w.minq.evict(o.seq)
+
+	if w.head > len(w.obs)/2 {
+		w.obs = append(w.obs[:0], w.obs[w.head:]...)
+		w.head = 0
+	}
+}
+
+// updateEMA folds an observation into a window's exponential moving average.
+// Count windows use a smoothing factor of 2/(Size+1); time windows decay with the time
+// elapsed since the previous observation, with Period as the time constant.
+func (w *RollingWindow) updateEMA(o windowObs) {
+	if w.seq == 0 {
+		w.ema = float64(o.price)
+		return
+	}
+	var alpha float64
+	switch {
+	case w.Size > 0:
+		alpha = 2 / float64(w.Size+1)
+	case w.Period > 0:
+		alpha = 1 - math.Exp(-float64(o.t.Sub(w.last.t))/float64(w.Period))
+	default:
+		alpha = 1 / float64(w.seq+1)
+	}
+	w.ema += alpha * (float64(o.price) - w.ema)
+}
+
+// ----------------------------------------------------------------------------
+
+// Len returns the number of observations in a window.
+func (w *RollingWindow) Len() int {
+	return len(w.obs) - w.head
+}
+
+// Mean returns the average price in a window, rounded to the nearest cent.
+func (w *RollingWindow) Mean() Price {
+	if w.Len() == 0 {
+		return 0
+	}
+	return Price(roundDiv(w.sum, Amount(w.Len())))
+}
+
+// Variance returns the sample variance of the prices in a window, in squared cents.
+func (w *RollingWindow) Variance() float64 {
+	n := float64(w.Len())
+	if n < 2 {
+		return 0
+	}
+	mean := float64(w.sum) / n
+	return math.Max(0, (w.sumSq-n*mean*mean)/(n-1))
+}
+
+// StdDev returns the sample standard deviation of the prices in a window.
+func (w *RollingWindow) StdDev() Price {
+	return Price(math.Round(math.Sqrt(w.Variance())))
+}
+
+// Max returns the highest price in a window and when it was most recently observed.
+func (w *RollingWindow) Max() SummaryMetric {
+	return w.maxq.front()
+}
+
+// Min returns the lowest price in a window and when it was most recently observed.
+func (w *RollingWindow) Min() SummaryMetric {
+	return w.minq.front()
+}
+
+// EMA returns the exponential moving average of every price added to a window.
+func (w *RollingWindow) EMA() Price {
+	return Price(math.Round(w.ema))
+}
+
+// RealizedVol returns the realized volatility of a window: the square root of the sum of
+// squared log returns of its observations, each against the observation before it.
+// It is not annualized.
+func (w *RollingWindow) RealizedVol() float64 {
+	return math.Sqrt(math.Max(0, w.sumRetSq))
+}
+
+// ----------------------------------------------------------------------------
+
+// deque is a monotonic queue of observations, used to track a window's extremes.
+type deque struct {
+	items []windowObs
+	head  int
+}
+
+// push appends an observation, first dropping from the back any it dominates.
+func (d *deque) push(o windowObs, dominated func(back windowObs) bool) {
+	for len(d.items) > d.head && dominated(d.items[len(d.items)-1]) {
+		d.items = d.items[:len(d.items)-1]
+	}
+	d.items = append(d.items, o)
+}
+
+// evict drops the front of the queue if it is the observation with the given sequence number.
+func (d *deque) evict(seq int) {
+	if len(d.items) > d.head && d.items[d.head].seq == seq {
+		d.head++
+	}
+	if d.head > len(d.items)/2 {
+		d.items = append(d.items[:0], d.items[d.head:]...)
+		d.head = 0
+	}
+}
+
+// front returns the observation at the front of the queue, or a zero metric if it is empty.
+func (d *deque) front() SummaryMetric {
+	if len(d.items) == d.head {
+		return SummaryMetric{}
+	}
+	o := d.items[d.head]
+	return SummaryMetric{Price: o.price, Date: o.t}
+}
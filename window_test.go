@@ -0,0 +1,152 @@
+// Copyright (c) 2017 Jake Schurch
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package instruments
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func mockWindowSeries() ([]Price, []time.Time) {
+	start := time.Date(2017, 1, 3, 9, 30, 0, 0, time.UTC)
+	prices := []Price{1000, 1010, 990, 1020, 1020, 980, 1000, 1050, 1040, 1030, 1060, 990}
+	times := make([]time.Time, len(prices))
+	for i := range prices {
+		times[i] = start.Add(time.Duration(i*i) * time.Second)
+	}
+	return prices, times
+}
+
+// naiveWindow recomputes the statistics of a window from scratch.
+func naiveWindow(prices []Price, times []time.Time) (mean Price, variance float64, max, min SummaryMetric) {
+	var sum float64
+	for i, p := range prices {
+		sum += float64(p)
+		if i == 0 || p >= max.Price {
+			max = SummaryMetric{p, times[i]}
+		}
+		if i == 0 || p <= min.Price {
+			min = SummaryMetric{p, times[i]}
+		}
+	}
+	n := float64(len(prices))
+	mean = Price(math.Round(sum / n))
+	for _, p := range prices {
+		variance += (float64(p) - sum/n) * (float64(p) - sum/n)
+	}
+	if n > 1 {
+		variance /= n - 1
+	}
+	return mean, variance, max, min
+}
+
+func TestRollingWindow_Add(t *testing.T) {
+	prices, times := mockWindowSeries()
+
+	tests := []struct {
+		name  string
+		w     *RollingWindow
+		start func(i int) int
+	}{
+		{"count", NewCountWindow(4), func(i int) int {
+			if i < 3 {
+				return 0
+			}
+			return i - 3
+		}},
+		{"time", NewTimeWindow(30 * time.Second), func(i int) int {
+			j := 0
+			for times[i].Sub(times[j]) >= 30*time.Second {
+				j++
+			}
+			return j
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range prices {
+				tt.w.Add(prices[i], times[i])
+
+				j := tt.start(i)
+				mean, variance, max, min := naiveWindow(prices[j:i+1], times[j:i+1])
+				if tt.w.Len() != i+1-j {
+					t.Fatalf("step %d: RollingWindow.Len() = %v, want %v", i, tt.w.Len(), i+1-j)
+				}
+				if tt.w.Mean() != mean || math.Abs(tt.w.Variance()-variance) > 1e-6 {
+					t.Errorf("step %d: RollingWindow mean = %v var = %v, want %v and %v", i, tt.w.Mean(), tt.w.Variance(), mean, variance)
+				}
+				if tt.w.Max() != max || tt.w.Min() != min {
+					t.Errorf("step %d: RollingWindow max = %v min = %v, want %v and %v", i, tt.w.Max(), tt.w.Min(), max, min)
+				}
+			}
+		})
+	}
+}
+
+func TestRollingWindow_EMA(t *testing.T) {
+	start := time.Date(2017, 1, 3, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		w      *RollingWindow
+		prices []Price
+		want   Price
+	}{
+		{"count", NewCountWindow(3), []Price{1000, 1100, 1200}, 1125},
+		{"time", NewTimeWindow(time.Second), []Price{1000, 2000}, 1632},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, p := range tt.prices {
+				tt.w.Add(p, start.Add(time.Duration(i)*time.Second))
+			}
+			if got := tt.w.EMA(); got != tt.want {
+				t.Errorf("RollingWindow.EMA() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollingWindow_RealizedVol(t *testing.T) {
+	start := time.Date(2017, 1, 3, 9, 30, 0, 0, time.UTC)
+	w := NewCountWindow(2)
+	for i, p := range []Price{1000, 2000, 1000, 1000} {
+		w.Add(p, start.Add(time.Duration(i)*time.Second))
+	}
+	if got, want := w.RealizedVol(), math.Ln2; math.Abs(got-want) > 1e-9 {
+		t.Errorf("RollingWindow.RealizedVol() = %v, want %v", got, want)
+	}
+}
+
+func TestSummary_Windows(t *testing.T) {
+	start := time.Date(2017, 1, 3, 9, 30, 0, 0, time.UTC)
+	s := mockSummary()
+	s.Windows = []*RollingWindow{NewCountWindow(2)}
+
+	s.UpdateMetrics(NewPrice(10.00), NewPrice(10.20), start)
+	s.UpdateMetrics(NewPrice(11.00), NewPrice(11.20), start.Add(time.Second))
+	s.UpdateMetrics(NewPrice(12.00), NewPrice(12.20), start.Add(2*time.Second))
+
+	if w := s.Windows[0]; w.Len() != 2 || w.Mean() != NewPrice(11.60) || w.Min().Price != NewPrice(11.10) {
+		t.Errorf("Summary window len = %v mean = %v min = %v", w.Len(), w.Mean(), w.Min())
+	}
+}